@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const defaultAddr = "http://localhost:8080"
+
+// Cancel implements "bigdataimporter cancel [-addr URL] <job-id>...": it asks
+// a running server to cancel the given jobs through DELETE /jobs/{id}. It
+// returns 0 when every cancellation was accepted.
+func Cancel(args []string) int {
+	fs := flag.NewFlagSet("cancel", flag.ContinueOnError)
+	addr := fs.String("addr", defaultAddr, "server address")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: bigdataimporter cancel [-addr URL] <job-id>...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	code := exitOK
+	for _, id := range fs.Args() {
+		if err := cancelJob(*addr, id); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", id, err)
+			code = exitFailure
+			continue
+		}
+		fmt.Printf("%s: cancellation requested\n", id)
+	}
+	return code
+}
+
+func cancelJob(addr, id string) error {
+	req, err := http.NewRequest(http.MethodDelete, strings.TrimRight(addr, "/")+"/jobs/"+url.PathEscape(id), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
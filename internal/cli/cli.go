@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+)
+
+// Exit codes of the subcommands.
+const (
+	exitOK          = 0
+	exitFailure     = 1   // the conversion or import failed
+	exitUsage       = 2   // bad flags or arguments
+	exitInterrupted = 130 // stopped by SIGINT/SIGTERM
+)
+
+const usage = `usage: bigdataimporter <command> [flags] [path|-]
+
+commands:
+  parse     print the schema read from a dump as JSON
+  generate  write the schema generated for a target
+  import    generate the schema and load the data into a target
+  serve     start the HTTP server (default when no command is given)
+  cancel    cancel jobs of a running server
+
+Inputs are read from a path, or from stdin when the path is "-" or missing.
+Run "bigdataimporter <command> -h" for the flags of a command.
+`
+
+// Run executes a command line without the program name and returns the
+// process exit code.
+func Run(args []string) int {
+	if len(args) == 0 {
+		return Serve(nil)
+	}
+	switch args[0] {
+	case "parse":
+		return Parse(args[1:])
+	case "generate":
+		return Generate(args[1:])
+	case "import":
+		return Import(args[1:])
+	case "serve":
+		return Serve(args[1:])
+	case "cancel":
+		return Cancel(args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return exitOK
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+}
+
+// signalContext is cancelled on SIGINT or SIGTERM, which rolls back the
+// batch being imported.
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// exitCode maps the error of a command onto its exit code.
+func exitCode(ctx context.Context, err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case ctx.Err() != nil || errors.Is(err, context.Canceled):
+		fmt.Fprintln(os.Stderr, "interrupted")
+		return exitInterrupted
+	default:
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+}
+
+// inputPath returns the file a command reads. Stdin is copied to a temporary
+// file first because the parsers read their input twice; the returned
+// function removes it.
+func inputPath(args []string) (string, func(), error) {
+	if len(args) > 1 {
+		return "", nil, fmt.Errorf("expected one input, got %d", len(args))
+	}
+	if len(args) == 1 && args[0] != "-" {
+		if _, err := os.Stat(args[0]); err != nil {
+			return "", nil, err
+		}
+		return args[0], func() {}, nil
+	}
+
+	dir, err := os.MkdirTemp("", "bigdataimporter-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	// CSV tables are named after their file.
+	path := filepath.Join(dir, "stdin")
+	f, err := os.Create(path)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	_, err = io.Copy(f, os.Stdin)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("stdin read error: %v", err)
+	}
+	return path, cleanup, nil
+}
+
+// output opens the file a command writes to, or stdout for "" and "-".
+func output(path string) (io.WriteCloser, error) {
+	if path == "" || path == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(path)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
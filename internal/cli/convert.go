@@ -0,0 +1,291 @@
+package cli
+
+import (
+	"bigdataimporter/internal/db"
+	"bigdataimporter/internal/executor"
+	"bigdataimporter/internal/generator"
+	"bigdataimporter/internal/parser"
+	"bigdataimporter/internal/worker"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// commandFlags are the flags shared by parse, generate and import.
+type commandFlags struct {
+	fs     *flag.FlagSet
+	from   *string
+	to     *string
+	embed  *bool
+	policy *string
+	out    *string
+	config *string
+}
+
+func newCommandFlags(name, synopsis string, withTarget bool) *commandFlags {
+	c := &commandFlags{fs: flag.NewFlagSet(name, flag.ContinueOnError)}
+	c.from = c.fs.String("from", "", "input format: mysql, postgres, sqlite, sqlite-db, csv, tsv or json (detected when empty)")
+	if withTarget {
+		c.to = c.fs.String("to", "", "target: postgres, mysql, sqlite or mongo")
+		c.embed = c.fs.Bool("embed", false, "mongo: embed child rows into their parent documents")
+		c.config = c.fs.String("config", "config.yaml", "config file with the database and type_mappings")
+		c.policy = c.fs.String("if-exists", "", "postgres: what to do with tables that already exist: "+generator.IfExistsPolicies()+" (default fail)")
+	}
+	c.fs.Usage = func() {
+		fmt.Fprintf(c.fs.Output(), "usage: bigdataimporter %s\n", synopsis)
+		c.fs.PrintDefaults()
+	}
+	return c
+}
+
+// parse parses the flags and reads the input, returning the exit code to
+// stop with when that fails.
+func (c *commandFlags) parse(args []string) (string, func(), int) {
+	if err := c.fs.Parse(args); err != nil {
+		return "", nil, exitUsage
+	}
+	if c.to != nil && *c.to == "" {
+		fmt.Fprintln(os.Stderr, "missing -to")
+		c.fs.Usage()
+		return "", nil, exitUsage
+	}
+	if c.to != nil && worker.SelectGenerator(worker.Job{Target: *c.to}, nil) == nil {
+		fmt.Fprintf(os.Stderr, "unsupported target: %s\n", *c.to)
+		return "", nil, exitUsage
+	}
+	if c.policy != nil && !generator.ValidIfExists(*c.policy) {
+		fmt.Fprintf(os.Stderr, "invalid -if-exists: %s (%s)\n", *c.policy, generator.IfExistsPolicies())
+		return "", nil, exitUsage
+	}
+	path, cleanup, err := inputPath(c.fs.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return "", nil, exitUsage
+	}
+	return path, cleanup, -1
+}
+
+// parseInput runs the schema pass of ParseFile. The returned cleanup is
+// never nil and is run once the RowSource is no longer needed.
+func parseInput(ctx context.Context, path, format string) ([]parser.ParsedTable, parser.RowSource, func(), error) {
+	tables, rows, cleanup, err := parser.ParseFile(ctx, path, format)
+	if err != nil {
+		return nil, nil, cleanup, fmt.Errorf("parse error in %s: %v", path, err)
+	}
+	if len(tables) == 0 {
+		cleanup()
+		return nil, nil, func() {}, fmt.Errorf("no tables found in %s", path)
+	}
+	return tables, rows, cleanup, nil
+}
+
+func (c *commandFlags) job() worker.Job {
+	return worker.Job{Target: *c.to, EmbedChildren: *c.embed, IfExists: *c.policy}
+}
+
+// typeMap builds the type mapping of the config file for the input at path.
+func (c *commandFlags) typeMap(path string) (*generator.TypeMap, error) {
+	source := *c.from
+	if source == "" {
+		source, _ = parser.DetectFormat(path)
+	}
+	return executor.TypeMap(*c.config, source)
+}
+
+func generateSchema(tables []parser.ParsedTable, job worker.Job, types *generator.TypeMap) (string, generator.Generator, []generator.Table, error) {
+	gen := worker.SelectGenerator(job, types)
+	genTables := worker.GeneratorTables(tables)
+	schema, err := gen.GenerateSchema(genTables)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("schema generation error: %v", err)
+	}
+	if len(schema) == 0 {
+		return "", nil, nil, fmt.Errorf("empty schema generated for %s", job.Target)
+	}
+	return schema, gen, genTables, nil
+}
+
+// Parse implements "bigdataimporter parse": it prints the tables read from
+// the input as JSON.
+func Parse(args []string) int {
+	c := newCommandFlags("parse", "parse [-from format] [-o file] [path|-]", false)
+	c.out = c.fs.String("o", "", "write the JSON to this file instead of stdout")
+	path, cleanup, code := c.parse(args)
+	if code >= 0 {
+		return code
+	}
+	defer cleanup()
+
+	ctx, stop := signalContext()
+	defer stop()
+	return exitCode(ctx, func() error {
+		tables, _, cleanupParse, err := parseInput(ctx, path, *c.from)
+		if err != nil {
+			return err
+		}
+		defer cleanupParse()
+		return writeOutput(*c.out, func(w *json.Encoder) error { return w.Encode(tables) })
+	}())
+}
+
+// Generate implements "bigdataimporter generate": it writes the schema
+// generated for the target to stdout or a file.
+func Generate(args []string) int {
+	c := newCommandFlags("generate", "generate -to target [-from format] [-config file] [-if-exists policy] [-o file] [path|-]", true)
+	c.out = c.fs.String("o", "", "write the schema to this file instead of stdout")
+	path, cleanup, code := c.parse(args)
+	if code >= 0 {
+		return code
+	}
+	defer cleanup()
+
+	ctx, stop := signalContext()
+	defer stop()
+	return exitCode(ctx, func() error {
+		tables, _, cleanupParse, err := parseInput(ctx, path, *c.from)
+		if err != nil {
+			return err
+		}
+		defer cleanupParse()
+		types, err := c.typeMap(path)
+		if err != nil {
+			return err
+		}
+		schema, _, _, err := generateSchema(tables, c.job(), types)
+		if err != nil {
+			return err
+		}
+		w, err := output(*c.out)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte(schema)); err != nil {
+			w.Close()
+			return err
+		}
+		return w.Close()
+	}())
+}
+
+// Import implements "bigdataimporter import": it generates the schema and
+// loads the data into the database of the config file, the same way the
+// server's workers do. Document targets are exported under -docs instead.
+// With -dry-run nothing is kept: the report of what the import would have
+// done is printed as JSON, and rejected rows make the command fail.
+func Import(args []string) int {
+	c := newCommandFlags("import", "import -to target [-from format] [-config file] [-o schema-file] [-if-exists policy] [-dry-run] [path|-]", true)
+	c.out = c.fs.String("o", "", "also keep the generated schema in this file")
+	docs := c.fs.String("docs", "", "directory for exported documents (default results/<target>)")
+	dryRun := c.fs.Bool("dry-run", false, "validate against the target and roll everything back")
+	path, cleanup, code := c.parse(args)
+	if code >= 0 {
+		return code
+	}
+	defer cleanup()
+
+	ctx, stop := signalContext()
+	defer stop()
+	return exitCode(ctx, func() error {
+		tables, rows, cleanupParse, err := parseInput(ctx, path, *c.from)
+		if err != nil {
+			return err
+		}
+		defer cleanupParse()
+		types, err := c.typeMap(path)
+		if err != nil {
+			return err
+		}
+		schema, gen, genTables, err := generateSchema(tables, c.job(), types)
+		if err != nil {
+			return err
+		}
+
+		if dw, ok := gen.(generator.DocumentWriter); ok {
+			if *dryRun {
+				return fmt.Errorf("dry run needs a database target, not %s", *c.to)
+			}
+			dir := *docs
+			if dir == "" {
+				dir = filepath.Join("results", *c.to)
+			}
+			if *c.out != "" {
+				if err := os.WriteFile(*c.out, []byte(schema), 0644); err != nil {
+					return err
+				}
+			}
+			if err := dw.WriteDocuments(ctx, dir, genTables, rows); err != nil {
+				return fmt.Errorf("document export failed: %v", err)
+			}
+			fmt.Fprintf(os.Stderr, "documents exported: %s\n", dir)
+			return nil
+		}
+
+		// The executor applies the schema from a file.
+		schemaPath := *c.out
+		if schemaPath == "" {
+			f, err := os.CreateTemp("", "bigdataimporter-*"+worker.SchemaExt(*c.to))
+			if err != nil {
+				return err
+			}
+			schemaPath = f.Name()
+			f.Close()
+			defer os.Remove(schemaPath)
+		}
+		if err := os.WriteFile(schemaPath, []byte(schema), 0644); err != nil {
+			return err
+		}
+
+		job := executor.Job{
+			ID:         "cli-import",
+			FilePath:   schemaPath,
+			Target:     *c.to,
+			ConfigPath: *c.config,
+			IfExists:   *c.policy,
+			Types:      types,
+			Preflight: func(existing []db.ExistingTable) {
+				for _, e := range existing {
+					fmt.Fprintf(os.Stderr, "table exists: %s (%d rows)\n", e.Table, e.Rows)
+				}
+			},
+		}
+		if *dryRun {
+			report, err := executor.DryRun(ctx, job, tables, rows)
+			if err != nil {
+				return err
+			}
+			if err := writeOutput("", func(w *json.Encoder) error { return w.Encode(report) }); err != nil {
+				return err
+			}
+			if report.SchemaError != "" {
+				return fmt.Errorf("dry run: schema does not apply: %s", report.SchemaError)
+			}
+			if !report.OK {
+				return fmt.Errorf("dry run found problems: %d of %d rows rejected", report.FailedRows, report.TotalRows)
+			}
+			return nil
+		}
+
+		err = executor.Run(ctx, job, tables, rows)
+		if err == nil {
+			fmt.Fprintf(os.Stderr, "imported %d tables into %s\n", len(tables), *c.to)
+		}
+		return err
+	}())
+}
+
+func writeOutput(path string, fn func(*json.Encoder) error) error {
+	w, err := output(path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := fn(enc); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
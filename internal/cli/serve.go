@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"bigdataimporter/internal/httpserver"
+	"bigdataimporter/internal/worker"
+	"bigdataimporter/setup"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// Serve implements "bigdataimporter serve": the HTTP server with its worker
+// pool and durable job queue. It only returns on bad flags.
+func Serve(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", ":8080", "listen address")
+	workers := fs.Int("workers", 4, "number of workers")
+	storePath := fs.String("store", filepath.Join("data", "jobs.db"), "job store file")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() > 0 || *workers < 1 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	if err := os.MkdirAll("logs", os.ModePerm); err != nil {
+		fmt.Println("logs klasörü oluşturulamadı:", err)
+	}
+
+	logFile, err := os.OpenFile("logs/app.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err == nil {
+		log.SetOutput(logFile)
+		log.Println("Loglama başlatıldı -> logs/app.log")
+	} else {
+		log.Println("Log dosyası oluşturulamadı, terminale yazılıyor:", err)
+	}
+
+	store, err := worker.OpenStore(*storePath)
+	if err != nil {
+		log.Printf("Job store açılamadı, kuyruk yalnızca bellekte tutulacak: %v", err)
+	} else {
+		defer store.Close()
+		worker.UseStore(store)
+	}
+
+	worker.StartPool(*workers)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "Starting bigdata-importer server...")
+	})
+
+	mux.HandleFunc("/upload-sql", httpserver.UploadSQLHandler)
+	mux.HandleFunc("/upload-csv", httpserver.UploadCSVHandler)
+	mux.HandleFunc("/jobs", httpserver.JobsHandler)
+	mux.HandleFunc("/jobs/", httpserver.JobsHandler)
+	mux.HandleFunc("/schema/preview", httpserver.SchemaPreviewHandler)
+
+	srv := setup.NewServer(mux)
+	srv.Addr = *addr
+	setup.StartServer(srv)
+	return exitOK
+}
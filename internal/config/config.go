@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 
@@ -18,8 +19,39 @@ type DatabaseConfig struct {
 	SSLMode  string `yaml:"sslmode"`
 }
 
+type ImportConfig struct {
+	BatchSize int `yaml:"batch_size"`
+}
+
+// TypeMapping overrides the type columns get in one target. Every field
+// that is set must match; Table and Column are path.Match patterns. Type,
+// Length, Precision, Scale and Unsigned describe the column as a MySQL dump
+// spells it, whatever the input format.
+type TypeMapping struct {
+	Source    string `yaml:"source"` // input format, any when empty
+	Target    string `yaml:"target"`
+	Type      string `yaml:"type"` // base type, e.g. tinyint
+	Length    *int   `yaml:"length"`
+	Precision *int   `yaml:"precision"`
+	Scale     *int   `yaml:"scale"`
+	Unsigned  *bool  `yaml:"unsigned"`
+	Table     string `yaml:"table"`
+	Column    string `yaml:"column"`
+	To        string `yaml:"to"`
+}
+
+// PostgresConfig holds the options of the PostgreSQL target.
+type PostgresConfig struct {
+	// Enums is how ENUM columns are created: "type" for CREATE TYPE ... AS
+	// ENUM (the default) or "check" for a text column with a CHECK.
+	Enums string `yaml:"enums"`
+}
+
 type Config struct {
-	Database DatabaseConfig `yaml:"database"`
+	Database     DatabaseConfig `yaml:"database"`
+	Import       ImportConfig   `yaml:"import"`
+	Postgres     PostgresConfig `yaml:"postgres"`
+	TypeMappings []TypeMapping  `yaml:"type_mappings"`
 }
 
 func LoadConfig(filename string) (*Config, error) {
@@ -36,7 +68,7 @@ func LoadConfig(filename string) (*Config, error) {
 	for _, p := range paths {
 		data, err = os.ReadFile(p)
 		if err == nil {
-			fmt.Printf("Config loaded from: %s\n", p)
+			log.Printf("Config loaded from: %s", p)
 			break
 		}
 	}
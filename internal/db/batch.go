@@ -0,0 +1,148 @@
+package db
+
+import (
+	"bigdataimporter/internal/config"
+	"bigdataimporter/internal/parser"
+	"context"
+	"database/sql"
+	"log"
+)
+
+const defaultBatchSize = 5000
+
+// rowBatch collects the rows of one table until they are loaded together.
+type rowBatch struct {
+	table   string
+	columns []string
+	rows    [][]interface{}
+}
+
+func batchSize(cfg *config.Config) int {
+	if cfg == nil || cfg.Import.BatchSize <= 0 {
+		return defaultBatchSize
+	}
+	return cfg.Import.BatchSize
+}
+
+// batchRows regroups the INSERT batches of a RowSource into per-table batches
+// of at most size rows. Full batches are handed to load as soon as they fill
+// up; what is left is flushed in table order once the source is exhausted.
+// Once ctx is cancelled no further batch is loaded and ctx.Err() is returned.
+func batchRows(ctx context.Context, rows parser.RowSource, tables []parser.ParsedTable, size int, load func(*rowBatch)) error {
+	pending := map[string]*rowBatch{}
+
+	flush := func(b *rowBatch) {
+		if len(b.rows) == 0 || ctx.Err() != nil {
+			return
+		}
+		load(b)
+		b.rows = b.rows[:0]
+	}
+
+	err := rows.Each(ctx, func(ins parser.Insert) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if len(ins.Columns) == 0 {
+			return nil
+		}
+		b := pending[ins.Table]
+		if b == nil || !sameColumns(b.columns, ins.Columns) {
+			if b != nil {
+				flush(b)
+			}
+			b = &rowBatch{table: ins.Table, columns: ins.Columns}
+			pending[ins.Table] = b
+		}
+		for _, row := range ins.Rows {
+			b.rows = append(b.rows, row)
+			if len(b.rows) >= size {
+				flush(b)
+			}
+		}
+		return nil
+	})
+
+	for _, t := range tables {
+		if b := pending[t.TableName]; b != nil {
+			flush(b)
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// retryRowByRow loads a batch the target rejected again one row at a time,
+// each in a savepoint of a single transaction opened by begin and closed by
+// commit. Rows that fail are written to failedFile and skipped; it returns
+// how many rows were imported and how many failed.
+func retryRowByRow(ctx context.Context, conn *sql.DB, b *rowBatch, failedFile string,
+	begin func(context.Context, *sql.DB) (*sql.Tx, error), commit func(context.Context, *sql.Tx) error,
+	load func(context.Context, execer, *rowBatch) error) (int64, int64) {
+	// lost gives the whole batch up when the transaction itself fails.
+	lost := func(err error) (int64, int64) {
+		if ctx.Err() != nil {
+			return 0, 0
+		}
+		log.Printf("Insert error in %s: %v", b.table, err)
+		logFailedRows(failedFile, b.table, b.rows, err)
+		return 0, int64(len(b.rows))
+	}
+
+	txn, err := begin(ctx, conn)
+	if err != nil {
+		return lost(err)
+	}
+	defer txn.Rollback()
+
+	var imported, failed int64
+	for _, row := range b.rows {
+		one := &rowBatch{table: b.table, columns: b.columns, rows: [][]interface{}{row}}
+		if _, err := txn.ExecContext(ctx, "SAVEPOINT bdi_row"); err != nil {
+			return lost(err)
+		}
+		if err := load(ctx, txn, one); err != nil {
+			if ctx.Err() != nil {
+				return 0, 0
+			}
+			if _, err := txn.ExecContext(ctx, "ROLLBACK TO SAVEPOINT bdi_row"); err != nil {
+				return lost(err)
+			}
+			log.Printf("Insert error in %s: %v", b.table, err)
+			logFailedRows(failedFile, b.table, one.rows, err)
+			failed++
+		} else {
+			imported++
+		}
+		if _, err := txn.ExecContext(ctx, "RELEASE SAVEPOINT bdi_row"); err != nil {
+			return lost(err)
+		}
+	}
+	if err := commit(ctx, txn); err != nil {
+		return lost(err)
+	}
+	return imported, failed
+}
+
+// beginTx and commitTx open and close a plain load transaction.
+func beginTx(ctx context.Context, conn *sql.DB) (*sql.Tx, error) {
+	return conn.BeginTx(ctx, nil)
+}
+
+func commitTx(ctx context.Context, txn *sql.Tx) error {
+	return txn.Commit()
+}
+
+func sameColumns(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
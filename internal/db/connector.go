@@ -2,24 +2,30 @@ package db
 
 import (
 	"bigdataimporter/internal/config"
+	"bigdataimporter/internal/generator"
 	"bigdataimporter/internal/parser"
+	"context"
 	"database/sql"
 )
 
 type Connector interface {
 	Connect() (*sql.DB, error)
-	ApplySchema(conn *sql.DB, schema string) error
-	ImportData(conn *sql.DB, tables []parser.ParsedTable) error
+	ApplySchema(ctx context.Context, conn *sql.DB, schema string) error
+	ImportData(ctx context.Context, conn *sql.DB, tables []parser.ParsedTable, rows parser.RowSource) error
 }
 
-func SelectConnector(target string, cfg *config.Config) Connector {
+// SelectConnector returns the connector of a target. types is the mapping
+// the schema was generated with; values are adapted to the same types.
+func SelectConnector(target string, cfg *config.Config, types *generator.TypeMap) Connector {
 	switch target {
 	case "postgres", "postgresql":
-		return &PostgresConnector{Cfg: cfg}
+		return &PostgresConnector{Cfg: cfg, Types: types}
 	// case "mongo":
 	// 	return &MongoConnector{Cfg: cfg}
-	// case "sqlite":
-	// 	return &SQLiteConnector{Cfg: cfg}
+	case "sqlite":
+		return &SQLiteConnector{Cfg: cfg, Types: types}
+	case "mysql", "mariadb":
+		return &MySQLConnector{Cfg: cfg}
 	default:
 		return nil
 	}
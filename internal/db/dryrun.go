@@ -0,0 +1,200 @@
+package db
+
+import (
+	"bigdataimporter/internal/parser"
+	"context"
+	"database/sql"
+)
+
+// maxRowErrors caps the row errors kept per table; the rest are counted.
+const maxRowErrors = 100
+
+// DryRunner is implemented by connectors that can validate an import
+// against the real target and leave nothing behind.
+type DryRunner interface {
+	DryRun(ctx context.Context, conn *sql.DB, schema string, tables []parser.ParsedTable, rows parser.RowSource) (*DryRunReport, error)
+}
+
+// DryRunReport tells what an import would have done: whether the schema
+// applies and which rows the target rejects.
+type DryRunReport struct {
+	Target      string        `json:"target"`
+	OK          bool          `json:"ok"`
+	SchemaError string        `json:"schema_error,omitempty"`
+	Tables      []TableReport `json:"tables"`
+	TotalRows   int64         `json:"total_rows"`
+	ValidRows   int64         `json:"valid_rows"`
+	FailedRows  int64         `json:"failed_rows"`
+
+	// Existing lists the tables that were in the target before the run.
+	Existing []ExistingTable `json:"existing_tables,omitempty"`
+}
+
+type TableReport struct {
+	Table     string     `json:"table"`
+	Rows      int64      `json:"rows"`
+	Valid     int64      `json:"valid"`
+	Failed    int64      `json:"failed"`
+	Errors    []RowError `json:"errors,omitempty"`
+	Truncated bool       `json:"errors_truncated,omitempty"`
+}
+
+// RowError is a row the target rejected. Row is its 1-based position among
+// the rows of its table in the input.
+type RowError struct {
+	Row    int64         `json:"row"`
+	Error  string        `json:"error"`
+	Values []interface{} `json:"values,omitempty"`
+}
+
+// execer is what batch loaders need; both *sql.Tx and *sql.Conn provide it.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+func newDryRunReport(target string, tables []parser.ParsedTable) *DryRunReport {
+	report := &DryRunReport{Target: target, Tables: make([]TableReport, len(tables))}
+	for i, t := range tables {
+		report.Tables[i] = TableReport{Table: t.TableName, Rows: t.RowCount}
+	}
+	return report
+}
+
+func (r *DryRunReport) table(name string) *TableReport {
+	for i := range r.Tables {
+		if r.Tables[i].Table == name {
+			return &r.Tables[i]
+		}
+	}
+	r.Tables = append(r.Tables, TableReport{Table: name})
+	return &r.Tables[len(r.Tables)-1]
+}
+
+// schemaFailed ends a dry run whose schema did not apply.
+func (r *DryRunReport) schemaFailed(err error) (*DryRunReport, error) {
+	if _, ok := err.(*SchemaError); !ok {
+		return nil, err
+	}
+	r.SchemaError = err.Error()
+	r.finish()
+	return r, nil
+}
+
+// finish sums the table counters up.
+func (r *DryRunReport) finish() {
+	r.TotalRows, r.ValidRows, r.FailedRows = 0, 0, 0
+	for _, t := range r.Tables {
+		r.TotalRows += t.Rows
+		r.ValidRows += t.Valid
+		r.FailedRows += t.Failed
+	}
+	r.OK = r.SchemaError == "" && r.FailedRows == 0
+}
+
+// execInSavepoint runs query inside a savepoint of txn, so a failure does not
+// abort the surrounding transaction.
+func execInSavepoint(ctx context.Context, txn *sql.Tx, name, query string, args ...interface{}) error {
+	if _, err := txn.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+	if _, err := txn.ExecContext(ctx, query, args...); err != nil {
+		txn.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+		return err
+	}
+	_, err := txn.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+	return err
+}
+
+// dryRunLoad loads every batch inside txn and records the outcome in report.
+// Each batch runs in a savepoint; a batch that fails is rolled back to it
+// and retried row by row to find the rows at fault. The caller rolls txn
+// back.
+func dryRunLoad(ctx context.Context, txn *sql.Tx, report *DryRunReport, tables []parser.ParsedTable, rows parser.RowSource, size int,
+	load func(context.Context, execer, *rowBatch) error) error {
+	progress := parser.ProgressFrom(ctx)
+	seen := map[string]int64{}
+
+	// A failing savepoint leaves the transaction unusable: stop reading.
+	readCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	var loadErr error
+	fail := func(err error) {
+		loadErr = err
+		stop()
+	}
+
+	// inSavepoint loads b in a savepoint. rejected is the target's error
+	// for the batch, err a failure of the transaction itself.
+	inSavepoint := func(name string, b *rowBatch) (rejected, err error) {
+		if _, err := txn.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+			return nil, err
+		}
+		if rejected := load(ctx, txn, b); rejected != nil {
+			_, err := txn.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+			return rejected, err
+		}
+		_, err = txn.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+		return nil, err
+	}
+
+	err := batchRows(readCtx, rows, tables, size, func(b *rowBatch) {
+		if loadErr != nil {
+			return
+		}
+		t := report.table(b.table)
+		first := seen[b.table]
+		seen[b.table] += int64(len(b.rows))
+		defer progress.Imported(b.table, len(b.rows))
+
+		rejected, err := inSavepoint("bdi_batch", b)
+		if err != nil {
+			fail(err)
+			return
+		}
+		if rejected == nil {
+			t.Valid += int64(len(b.rows))
+			return
+		}
+
+		for i, row := range b.rows {
+			one := &rowBatch{table: b.table, columns: b.columns, rows: [][]interface{}{row}}
+			rowErr, err := inSavepoint("bdi_row", one)
+			if err != nil {
+				fail(err)
+				return
+			}
+			if rowErr == nil {
+				t.Valid++
+				continue
+			}
+			t.Failed++
+			if len(t.Errors) >= maxRowErrors {
+				t.Truncated = true
+				continue
+			}
+			t.Errors = append(t.Errors, RowError{Row: first + int64(i) + 1, Error: rowErr.Error(), Values: jsonValues(row)})
+		}
+		if _, err := txn.ExecContext(ctx, "RELEASE SAVEPOINT bdi_batch"); err != nil {
+			fail(err)
+		}
+	})
+	if loadErr != nil {
+		return loadErr
+	}
+	return err
+}
+
+// jsonValues makes row values readable in a JSON report.
+func jsonValues(row []interface{}) []interface{} {
+	values := make([]interface{}, len(row))
+	for i, v := range row {
+		switch val := v.(type) {
+		case []byte:
+			values[i] = string(val)
+		default:
+			values[i] = v
+		}
+	}
+	return values
+}
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"bigdataimporter/internal/config"
+	"bigdataimporter/internal/parser"
+)
+
+const dryRunSchema = "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL);\n"
+
+func dryRunTables(rows int) []parser.ParsedTable {
+	return []parser.ParsedTable{{
+		TableName: "users",
+		RowCount:  int64(rows),
+		Fields:    []parser.Field{{Name: "id", Type: "int"}, {Name: "name", Type: "varchar(20)"}},
+	}}
+}
+
+// TestSQLiteDryRun loads batches of two rows: the batch holding a rejected
+// row is retried row by row, which finds the row at fault and keeps the
+// others valid. Nothing is left in the database.
+func TestSQLiteDryRun(t *testing.T) {
+	rows := [][]interface{}{{int64(1), "a"}, {int64(2), "b"}, {int64(3), nil}, {int64(4), "d"}, {int64(5), "e"}}
+	conn := openSQLite(t)
+	s := &SQLiteConnector{Cfg: &config.Config{Import: config.ImportConfig{BatchSize: 2}}}
+
+	report, err := s.DryRun(context.Background(), conn, dryRunSchema, dryRunTables(len(rows)),
+		staticRows{{Table: "users", Columns: []string{"id", "name"}, Rows: rows}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.OK || report.TotalRows != 5 || report.ValidRows != 4 || report.FailedRows != 1 {
+		t.Errorf("got ok %v, %d rows, %d valid, %d failed; want false, 5, 4, 1",
+			report.OK, report.TotalRows, report.ValidRows, report.FailedRows)
+	}
+	errs := report.Tables[0].Errors
+	if len(errs) != 1 {
+		t.Fatalf("got errors %+v, want one", errs)
+	}
+	if errs[0].Row != 3 || !strings.Contains(errs[0].Error, "NOT NULL constraint failed") ||
+		!reflect.DeepEqual(errs[0].Values, []interface{}{int64(3), nil}) {
+		t.Errorf("got %+v, want row 3 rejected for its NULL name", errs[0])
+	}
+
+	var n int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("got %d tables after the dry run, want 0", n)
+	}
+}
+
+func TestSQLiteDryRunTruncatesErrors(t *testing.T) {
+	var rows [][]interface{}
+	for i := 1; i <= maxRowErrors+5; i++ {
+		rows = append(rows, []interface{}{int64(i), nil})
+	}
+	conn := openSQLite(t)
+	s := &SQLiteConnector{Cfg: &config.Config{Import: config.ImportConfig{BatchSize: 50}}}
+
+	report, err := s.DryRun(context.Background(), conn, dryRunSchema, dryRunTables(len(rows)),
+		staticRows{{Table: "users", Columns: []string{"id", "name"}, Rows: rows}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := report.Tables[0]
+	if table.Failed != int64(len(rows)) || len(table.Errors) != maxRowErrors || !table.Truncated {
+		t.Errorf("got %d failed, %d errors, truncated %v; want %d, %d, true",
+			table.Failed, len(table.Errors), table.Truncated, len(rows), maxRowErrors)
+	}
+}
+
+func TestSQLiteDryRunSchemaError(t *testing.T) {
+	conn := openSQLite(t)
+	s := &SQLiteConnector{}
+
+	report, err := s.DryRun(context.Background(), conn, dryRunSchema+"CREATE TABLE users (id INTEGER);\n", dryRunTables(0), staticRows{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.OK || !strings.HasPrefix(report.SchemaError, "line 2: ") {
+		t.Errorf("got ok %v schema error %q, want the statement on line 2", report.OK, report.SchemaError)
+	}
+}
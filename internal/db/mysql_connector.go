@@ -0,0 +1,247 @@
+package db
+
+import (
+	"bigdataimporter/internal/config"
+	"bigdataimporter/internal/generator"
+	"bigdataimporter/internal/parser"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL accepts at most 65535 placeholders per prepared statement.
+const maxMySQLParams = 65535
+
+type MySQLConnector struct {
+	Cfg *config.Config
+}
+
+func (m *MySQLConnector) Connect() (*sql.DB, error) {
+	dsn := mysql.NewConfig()
+	dsn.User = m.Cfg.Database.User
+	dsn.Passwd = m.Cfg.Database.Password
+	dsn.Net = "tcp"
+	dsn.Addr = fmt.Sprintf("%s:%d", m.Cfg.Database.Host, m.Cfg.Database.Port)
+	dsn.DBName = m.Cfg.Database.Name
+	dsn.MultiStatements = true
+	dsn.Params = map[string]string{"charset": "utf8mb4"}
+
+	db, err := sql.Open("mysql", dsn.FormatDSN())
+	if err != nil {
+		return nil, fmt.Errorf("connection failed: %v", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("ping failed: %v", err)
+	}
+
+	log.Printf("MySQL bağlantısı başarılı: %s:%d/%s",
+		m.Cfg.Database.Host,
+		m.Cfg.Database.Port,
+		m.Cfg.Database.Name,
+	)
+	return db, nil
+}
+
+// ApplySchema runs the schema statement by statement on one session, which
+// keeps its SET FOREIGN_KEY_CHECKS in effect. MySQL commits DDL implicitly,
+// so when a statement fails the tables created before it are dropped again.
+func (m *MySQLConnector) ApplySchema(ctx context.Context, conn *sql.DB, schema string) error {
+	stmts, err := splitSchema(schema, parser.NewStatementReader)
+	if err != nil {
+		return err
+	}
+	c, err := conn.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("schema apply error: %v", err)
+	}
+	defer c.Close()
+
+	var created []string
+	err = applyStatements(ctx, c, stmts, func(stmt schemaStatement) {
+		if table, ok := createdTable(stmt.sql); ok {
+			created = append(created, table)
+		}
+	})
+	if err != nil {
+		for i := len(created) - 1; i >= 0; i-- {
+			if _, dropErr := c.ExecContext(context.Background(), "DROP TABLE IF EXISTS "+created[i]); dropErr != nil {
+				log.Printf("Rollback: %s silinemedi: %v", created[i], dropErr)
+			}
+		}
+		if len(created) > 0 {
+			log.Printf("Rollback: %d tablo silindi", len(created))
+		}
+		c.ExecContext(context.Background(), "SET FOREIGN_KEY_CHECKS=1")
+		return schemaApplyError(err)
+	}
+	log.Printf("Schema başarıyla uygulandı (%s)", m.Cfg.Database.Name)
+	return nil
+}
+
+func (m *MySQLConnector) ImportData(ctx context.Context, conn *sql.DB, tables []parser.ParsedTable, rows parser.RowSource) error {
+	logDir := "logs"
+	os.MkdirAll(logDir, 0755)
+	failedFile := filepath.Join(logDir, "failed_rows.log")
+
+	imported := map[string]int64{}
+	failed := map[string]int64{}
+	progress := parser.ProgressFrom(ctx)
+
+	err := batchRows(ctx, rows, tables, batchSize(m.Cfg), func(b *rowBatch) {
+		if err := insertMySQLRows(ctx, conn, b); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Insert failed for %s (%d rows), retrying row by row: %v", b.table, len(b.rows), err)
+			n, lost := retryRowByRow(ctx, conn, b, failedFile, beginMySQLLoadTx, commitMySQLLoadTx, insertMySQLBatch)
+			imported[b.table] += n
+			failed[b.table] += lost
+			progress.Imported(b.table, int(n))
+			return
+		}
+		imported[b.table] += int64(len(b.rows))
+		progress.Imported(b.table, len(b.rows))
+	})
+
+	for _, t := range tables {
+		if n := imported[t.TableName]; n > 0 {
+			log.Printf("%s data imported successfully (%d/%d rows)", t.TableName, n, t.RowCount)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return rejectedRowsError(tables, failed, failedFile)
+}
+
+// DryRun validates the import in a scratch database that is dropped at the
+// end: MySQL commits DDL implicitly, so a transaction alone cannot undo the
+// schema. Rows are still loaded inside a transaction that is rolled back.
+func (m *MySQLConnector) DryRun(ctx context.Context, conn *sql.DB, schema string, tables []parser.ParsedTable, rows parser.RowSource) (*DryRunReport, error) {
+	c, err := conn.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	scratch := fmt.Sprintf("bdi_dryrun_%d", time.Now().UnixNano())
+	if _, err := c.ExecContext(ctx, "CREATE DATABASE "+generator.QuoteMySQLIdent(scratch)); err != nil {
+		return nil, fmt.Errorf("scratch database error: %v", err)
+	}
+	defer func() {
+		// Cleanup runs even when ctx was cancelled.
+		c.ExecContext(context.Background(), "DROP DATABASE IF EXISTS "+generator.QuoteMySQLIdent(scratch))
+		if m.Cfg.Database.Name != "" {
+			c.ExecContext(context.Background(), "USE "+generator.QuoteMySQLIdent(m.Cfg.Database.Name))
+		}
+	}()
+	if _, err := c.ExecContext(ctx, "USE "+generator.QuoteMySQLIdent(scratch)); err != nil {
+		return nil, err
+	}
+
+	report := newDryRunReport("mysql", tables)
+	stmts, err := splitSchema(schema, parser.NewStatementReader)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyStatements(ctx, c, stmts, nil); err != nil {
+		return report.schemaFailed(err)
+	}
+	if _, err := c.ExecContext(ctx, "SET FOREIGN_KEY_CHECKS=0"); err != nil {
+		return nil, err
+	}
+	defer c.ExecContext(context.Background(), "SET FOREIGN_KEY_CHECKS=1")
+
+	txn, err := c.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Rollback()
+
+	err = dryRunLoad(ctx, txn, report, tables, rows, batchSize(m.Cfg), insertMySQLBatch)
+	report.finish()
+	return report, err
+}
+
+// insertMySQLRows writes one batch with multi-row INSERTs inside a single
+// transaction. Foreign key checks are off for that session because tables
+// are loaded in dump order, not dependency order.
+func insertMySQLRows(ctx context.Context, conn *sql.DB, b *rowBatch) error {
+	txn, err := beginMySQLLoadTx(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	if err := insertMySQLBatch(ctx, txn, b); err != nil {
+		return err
+	}
+	return commitMySQLLoadTx(ctx, txn)
+}
+
+// beginMySQLLoadTx starts a load transaction with foreign key checks off
+// for its session; commitMySQLLoadTx turns them back on and commits.
+func beginMySQLLoadTx(ctx context.Context, conn *sql.DB) (*sql.Tx, error) {
+	txn, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := txn.ExecContext(ctx, "SET FOREIGN_KEY_CHECKS=0"); err != nil {
+		txn.Rollback()
+		return nil, err
+	}
+	return txn, nil
+}
+
+func commitMySQLLoadTx(ctx context.Context, txn *sql.Tx) error {
+	if _, err := txn.ExecContext(ctx, "SET FOREIGN_KEY_CHECKS=1"); err != nil {
+		return err
+	}
+	return txn.Commit()
+}
+
+func insertMySQLBatch(ctx context.Context, ex execer, b *rowBatch) error {
+	quoted := make([]string, len(b.columns))
+	for i, c := range b.columns {
+		quoted[i] = generator.QuoteMySQLIdent(c)
+	}
+	prefix := fmt.Sprintf("INSERT INTO %s (%s) VALUES ", generator.QuoteMySQLIdent(b.table), strings.Join(quoted, ", "))
+	tuple := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(b.columns)), ", ") + ")"
+
+	perStmt := min(1000, max(1, maxMySQLParams/len(b.columns)))
+	for start := 0; start < len(b.rows); start += perStmt {
+		chunk := b.rows[start:min(start+perStmt, len(b.rows))]
+
+		var sb strings.Builder
+		args := make([]interface{}, 0, len(chunk)*len(b.columns))
+		sb.WriteString(prefix)
+		for ri, row := range chunk {
+			if ri > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(tuple)
+			for ci := range b.columns {
+				var v interface{}
+				if ci < len(row) {
+					v = row[ci]
+				}
+				if n, ok := v.(parser.Number); ok {
+					v = string(n)
+				}
+				args = append(args, v)
+			}
+		}
+		if _, err := ex.ExecContext(ctx, sb.String(), args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -4,16 +4,21 @@ import (
 	"bigdataimporter/internal/config"
 	"bigdataimporter/internal/generator"
 	"bigdataimporter/internal/parser"
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type PostgresConnector struct {
-	Cfg *config.Config
+	Cfg   *config.Config
+	Types *generator.TypeMap // the mapping the schema was generated with
 }
 
 func (p *PostgresConnector) Connect() (*sql.DB, error) {
@@ -44,35 +49,314 @@ func (p *PostgresConnector) Connect() (*sql.DB, error) {
 	return db, nil
 }
 
-func (p *PostgresConnector) ApplySchema(conn *sql.DB, schema string) error {
-	_, err := conn.Exec(schema)
+// ApplySchema runs the schema statement by statement in one transaction;
+// PostgreSQL DDL is transactional, so a failure leaves no table behind.
+func (p *PostgresConnector) ApplySchema(ctx context.Context, conn *sql.DB, schema string) error {
+	stmts, err := splitSchema(schema, parser.NewPostgresStatementReader)
 	if err != nil {
-		return fmt.Errorf("schema apply error: %v", err)
+		return err
+	}
+	if err := applySchemaTx(ctx, conn, stmts); err != nil {
+		return schemaApplyError(err)
 	}
 	log.Printf("Schema başarıyla uygulandı (%s)", p.Cfg.Database.Name)
 	return nil
 }
-func (p *PostgresConnector) ImportData(conn *sql.DB, tables []parser.ParsedTable) error {
+
+// Preflight lists the tables of the import that already exist in the
+// current schema, with their exact row counts. The generated schema uses
+// unquoted names, which PostgreSQL folds to lower case.
+func (p *PostgresConnector) Preflight(ctx context.Context, conn *sql.DB, tables []parser.ParsedTable) ([]ExistingTable, error) {
+	var existing []ExistingTable
+	for _, t := range tables {
+		name := strings.ToLower(t.TableName)
+		var found bool
+		err := conn.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1)`,
+			name).Scan(&found)
+		if err != nil {
+			return nil, fmt.Errorf("preflight error (%s): %v", t.TableName, err)
+		}
+		if !found {
+			continue
+		}
+		e := ExistingTable{Table: t.TableName}
+		if err := conn.QueryRowContext(ctx, "SELECT count(*) FROM "+pq.QuoteIdentifier(name)).Scan(&e.Rows); err != nil {
+			return nil, fmt.Errorf("preflight count error (%s): %v", t.TableName, err)
+		}
+		existing = append(existing, e)
+	}
+	return existing, nil
+}
+
+// PostgreSQL accepts at most 65535 bind parameters per statement.
+const maxPostgresParams = 65535
+
+func (p *PostgresConnector) ImportData(ctx context.Context, conn *sql.DB, tables []parser.ParsedTable, rows parser.RowSource) error {
 	logDir := "logs"
 	os.MkdirAll(logDir, 0755)
-	failedFile := logDir + "/failed_inserts.sql"
+	failedFile := filepath.Join(logDir, "failed_rows.log")
+
+	colTypes := postgresColumnTypes(tables, p.Types)
+	imported := map[string]int64{}
+	failed := map[string]int64{}
+	progress := parser.ProgressFrom(ctx)
+	log.Println("Foreign key checks disabled in each load transaction")
+
+	err := batchRows(ctx, rows, tables, batchSize(p.Cfg), func(b *rowBatch) {
+		types := colTypes[b.table]
+		if err := copyRows(ctx, conn, b, types); err != nil {
+			if ctx.Err() != nil {
+				// Cancelled: the batch was rolled back, nothing to retry.
+				return
+			}
+			log.Printf("COPY failed for %s (%d rows), falling back to INSERT: %v", b.table, len(b.rows), err)
+			n, lost := insertRows(ctx, conn, b, types, failedFile)
+			imported[b.table] += n
+			failed[b.table] += lost
+			progress.Imported(b.table, int(n))
+			return
+		}
+		imported[b.table] += int64(len(b.rows))
+		progress.Imported(b.table, len(b.rows))
+	})
 
 	for _, t := range tables {
-		if len(t.Inserts) == 0 {
+		if n := imported[t.TableName]; n > 0 {
+			log.Printf("%s data imported successfully (%d/%d rows)", t.TableName, n, t.RowCount)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return rejectedRowsError(tables, failed, failedFile)
+}
+
+// rejectedRowsError reports the rows the INSERT fallback could not load, so
+// a lossy import does not end as a success.
+func rejectedRowsError(tables []parser.ParsedTable, failed map[string]int64, failedFile string) error {
+	var list []string
+	for _, t := range tables {
+		if n := failed[t.TableName]; n > 0 {
+			list = append(list, fmt.Sprintf("%s (%d rows)", t.TableName, n))
+		}
+	}
+	if len(list) == 0 {
+		return nil
+	}
+	return fmt.Errorf("rows rejected: %s; see %s", strings.Join(list, ", "), failedFile)
+}
+
+// DryRun applies the schema and loads every row inside one transaction that
+// is rolled back at the end; PostgreSQL DDL is transactional, so nothing is
+// left behind.
+func (p *PostgresConnector) DryRun(ctx context.Context, conn *sql.DB, schema string, tables []parser.ParsedTable, rows parser.RowSource) (*DryRunReport, error) {
+	txn, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Rollback()
+
+	report := newDryRunReport("postgres", tables)
+	// Like the real import, load in dump order without foreign key checks
+	// when the role allows it.
+	execInSavepoint(ctx, txn, "bdi_role", "SET LOCAL session_replication_role = replica")
+
+	stmts, err := splitSchema(schema, parser.NewPostgresStatementReader)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyStatements(ctx, txn, stmts, nil); err != nil {
+		return report.schemaFailed(err)
+	}
+
+	colTypes := postgresColumnTypes(tables, p.Types)
+	err = dryRunLoad(ctx, txn, report, tables, rows, batchSize(p.Cfg), func(ctx context.Context, ex execer, b *rowBatch) error {
+		return copyBatch(ctx, ex, b, colTypes[b.table])
+	})
+	report.finish()
+	return report, err
+}
+
+// beginLoadTx starts a transaction for loading rows. Rows come in dump
+// order, so foreign key checks are turned off for the transaction when the
+// role allows it; a SET on the pool would reach one connection only.
+func beginLoadTx(ctx context.Context, conn *sql.DB) (*sql.Tx, error) {
+	txn, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	execInSavepoint(ctx, txn, "bdi_role", "SET LOCAL session_replication_role = replica")
+	return txn, nil
+}
+
+// copyRows streams one batch through COPY FROM STDIN inside its own
+// transaction, so a failing or cancelled batch leaves nothing behind.
+func copyRows(ctx context.Context, conn *sql.DB, b *rowBatch, types map[string]string) error {
+	txn, err := beginLoadTx(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	if err := copyBatch(ctx, txn, b, types); err != nil {
+		return err
+	}
+	return txn.Commit()
+}
+
+// copyBatch runs COPY FROM STDIN for one batch; ex must be a transaction.
+func copyBatch(ctx context.Context, ex execer, b *rowBatch, types map[string]string) error {
+	// The generator emits unquoted identifiers, which PostgreSQL folds to lower case.
+	columns := make([]string, len(b.columns))
+	for i, c := range b.columns {
+		columns[i] = strings.ToLower(c)
+	}
+
+	stmt, err := ex.PrepareContext(ctx, pq.CopyIn(strings.ToLower(b.table), columns...))
+	if err != nil {
+		return err
+	}
+
+	args := make([]interface{}, len(b.columns))
+	for _, row := range b.rows {
+		for i, col := range b.columns {
+			args[i] = nil
+			if i < len(row) {
+				args[i] = postgresValue(row[i], types[col])
+			}
+		}
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			stmt.Close()
+			return err
+		}
+	}
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		return err
+	}
+	return stmt.Close()
+}
+
+// insertRows loads a batch with multi-row INSERT statements. Statements that
+// fail are written to failedFile and skipped; their rows are counted as
+// failed.
+func insertRows(ctx context.Context, conn *sql.DB, b *rowBatch, types map[string]string, failedFile string) (int64, int64) {
+	var imported, failed int64
+	perStmt := max(1, maxPostgresParams/len(b.columns))
+
+	for start := 0; start < len(b.rows); start += perStmt {
+		chunk := b.rows[start:min(start+perStmt, len(b.rows))]
+		query, args := buildPostgresInsert(b.table, b.columns, chunk, types)
+		if err := insertChunk(ctx, conn, query, args); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			log.Printf("Insert error in %s: %v", b.table, err)
+			logFailedRows(failedFile, b.table, chunk, err)
+			failed += int64(len(chunk))
 			continue
 		}
+		imported += int64(len(chunk))
+	}
+	return imported, failed
+}
 
-		log.Printf("Importing %d inserts into %s...", len(t.Inserts), t.TableName)
-		for _, insertSQL := range t.Inserts {
-			normalized := generator.NormalizePostgresSyntax(insertSQL)
-			normalized = generator.SafeNormalize(normalized)
+func insertChunk(ctx context.Context, conn *sql.DB, query string, args []interface{}) error {
+	txn, err := beginLoadTx(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+	if _, err := txn.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+	return txn.Commit()
+}
 
-			if _, err := conn.Exec(normalized); err != nil {
-				log.Printf("Insert error in %s: %v", t.TableName, err)
-				os.WriteFile(failedFile, []byte(normalized+";\n\n"), os.ModeAppend)
+func postgresColumnTypes(tables []parser.ParsedTable, typeMap *generator.TypeMap) map[string]map[string]string {
+	types := map[string]map[string]string{}
+	for _, t := range tables {
+		cols := map[string]string{}
+		for _, f := range t.Fields {
+			cols[f.Name] = strings.ToUpper(typeMap.Column(generator.DialectPostgres, t.TableName, f.Name, f.ColumnType(), f.AutoIncrement))
+		}
+		types[t.TableName] = cols
+	}
+	return types
+}
+
+func buildPostgresInsert(table string, columns []string, rows [][]interface{}, types map[string]string) (string, []interface{}) {
+	var sb strings.Builder
+	args := make([]interface{}, 0, len(rows)*len(columns))
+
+	// Same names as copyBatch: folded to lower case like the generated
+	// schema, and quoted against reserved words.
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = pq.QuoteIdentifier(strings.ToLower(c))
+	}
+	sb.WriteString(fmt.Sprintf("INSERT INTO %s (%s) VALUES ", pq.QuoteIdentifier(strings.ToLower(table)), strings.Join(quoted, ", ")))
+	for ri, row := range rows {
+		if ri > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteByte('(')
+		for ci, col := range columns {
+			if ci > 0 {
+				sb.WriteString(", ")
 			}
+			var v interface{}
+			if ci < len(row) {
+				v = row[ci]
+			}
+			args = append(args, postgresValue(v, types[col]))
+			sb.WriteString(fmt.Sprintf("$%d", len(args)))
 		}
-		log.Printf("%s data imported successfully", t.TableName)
+		sb.WriteByte(')')
+	}
+	return sb.String(), args
+}
+
+// postgresValue adapts a decoded MySQL value to what PostgreSQL accepts for
+// the column type.
+func postgresValue(v interface{}, pgType string) interface{} {
+	switch val := v.(type) {
+	case string:
+		if (strings.HasPrefix(pgType, "DATE") || strings.HasPrefix(pgType, "TIMESTAMP")) &&
+			strings.HasPrefix(val, "0000-00-00") {
+			return "1970-01-01" + val[len("0000-00-00"):]
+		}
+		if pgType == "BYTEA" {
+			return []byte(val)
+		}
+		if pgType == "TEXT[]" {
+			return generator.PostgresArrayLiteral(val)
+		}
+		return val
+	case []byte:
+		if pgType == "TEXT[]" {
+			return generator.PostgresArrayLiteral(string(val))
+		}
+		if pgType != "BYTEA" {
+			return string(val)
+		}
+		return val
+	default:
+		return v
+	}
+}
+
+func logFailedRows(path, table string, rows [][]interface{}, cause error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	fmt.Fprintf(f, "-- %s: %v\n", table, cause)
+	enc := json.NewEncoder(f)
+	for _, row := range rows {
+		enc.Encode(row)
 	}
-	return nil
 }
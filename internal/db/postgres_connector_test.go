@@ -0,0 +1,76 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+
+	"bigdataimporter/internal/parser"
+)
+
+func TestBuildPostgresInsert(t *testing.T) {
+	tests := []struct {
+		name      string
+		table     string
+		columns   []string
+		rows      [][]interface{}
+		types     map[string]string
+		wantQuery string
+		wantArgs  []interface{}
+	}{
+		{
+			name:      "reserved and mixed-case names",
+			table:     "Order",
+			columns:   []string{"User", "id"},
+			rows:      [][]interface{}{{"a", int64(1)}, {"b", int64(2)}},
+			wantQuery: `INSERT INTO "order" ("user", "id") VALUES ($1, $2), ($3, $4)`,
+			wantArgs:  []interface{}{"a", int64(1), "b", int64(2)},
+		},
+		{
+			name:      "short row and converted values",
+			table:     "t",
+			columns:   []string{"d", "b", "n"},
+			rows:      [][]interface{}{{"0000-00-00 10:00:00", "xy"}},
+			types:     map[string]string{"d": "TIMESTAMP", "b": "BYTEA"},
+			wantQuery: `INSERT INTO "t" ("d", "b", "n") VALUES ($1, $2, $3)`,
+			wantArgs:  []interface{}{"1970-01-01 10:00:00", []byte("xy"), nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args := buildPostgresInsert(tt.table, tt.columns, tt.rows, tt.types)
+			if query != tt.wantQuery {
+				t.Errorf("got query %s, want %s", query, tt.wantQuery)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("got args %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestRejectedRowsError(t *testing.T) {
+	tables := []parser.ParsedTable{{TableName: "a"}, {TableName: "b"}, {TableName: "c"}}
+
+	tests := []struct {
+		name   string
+		failed map[string]int64
+		want   string
+	}{
+		{"none", map[string]int64{}, ""},
+		{"in table order", map[string]int64{"c": 1, "a": 20}, "rows rejected: a (20 rows), c (1 rows); see logs/failed_rows.log"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := rejectedRowsError(tables, tt.failed, "logs/failed_rows.log")
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
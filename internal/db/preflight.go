@@ -0,0 +1,68 @@
+package db
+
+import (
+	"bigdataimporter/internal/generator"
+	"bigdataimporter/internal/parser"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// ExistingTable is a target table that was there before the import.
+type ExistingTable struct {
+	Table string `json:"table"`
+	Rows  int64  `json:"rows"`
+}
+
+// Preflighter is implemented by connectors that can tell which tables of an
+// import already exist in the target, before the schema is applied.
+type Preflighter interface {
+	Preflight(ctx context.Context, conn *sql.DB, tables []parser.ParsedTable) ([]ExistingTable, error)
+}
+
+// CheckExisting applies the fail policy: any existing table stops the
+// import before anything is written.
+func CheckExisting(ifExists string, existing []ExistingTable) error {
+	if len(existing) == 0 || (ifExists != "" && ifExists != generator.IfExistsFail) {
+		return nil
+	}
+	list := make([]string, len(existing))
+	for i, e := range existing {
+		list[i] = fmt.Sprintf("%s (%d rows)", e.Table, e.Rows)
+	}
+	return fmt.Errorf("tables already exist: %s; choose if_exists=%s", strings.Join(list, ", "), generator.IfExistsPolicies())
+}
+
+// SkipExisting applies the skip policy: the existing tables are left out of
+// the data load.
+func SkipExisting(ifExists string, existing []ExistingTable, tables []parser.ParsedTable, rows parser.RowSource) ([]parser.ParsedTable, parser.RowSource) {
+	if ifExists != generator.IfExistsSkip || len(existing) == 0 {
+		return tables, rows
+	}
+	skip := map[string]bool{}
+	for _, e := range existing {
+		skip[e.Table] = true
+	}
+	var kept []parser.ParsedTable
+	for _, t := range tables {
+		if !skip[t.TableName] {
+			kept = append(kept, t)
+		}
+	}
+	return kept, &skipRows{RowSource: rows, skip: skip}
+}
+
+type skipRows struct {
+	parser.RowSource
+	skip map[string]bool
+}
+
+func (s *skipRows) Each(ctx context.Context, fn func(parser.Insert) error) error {
+	return s.RowSource.Each(ctx, func(ins parser.Insert) error {
+		if s.skip[ins.Table] {
+			return nil
+		}
+		return fn(ins)
+	})
+}
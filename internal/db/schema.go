@@ -0,0 +1,116 @@
+package db
+
+import (
+	"bigdataimporter/internal/parser"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// SchemaError reports the schema statement a target rejected. Line is the
+// line the statement starts on in the applied schema, which is the merged
+// schema file written by the worker.
+type SchemaError struct {
+	Line      int
+	Statement string
+	Err       error
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("line %d: %v (statement: %s)", e.Line, e.Err, e.Summary())
+}
+
+func (e *SchemaError) Unwrap() error {
+	return e.Err
+}
+
+// Summary shortens the statement to its first line for messages.
+func (e *SchemaError) Summary() string {
+	stmt := e.Statement
+	if i := strings.IndexByte(stmt, '\n'); i >= 0 {
+		stmt = strings.TrimSpace(stmt[:i]) + " ..."
+	}
+	if len(stmt) > 120 {
+		stmt = stmt[:120] + " ..."
+	}
+	return stmt
+}
+
+// schemaApplyError keeps a *SchemaError as it is for the caller to locate,
+// and a cancellation or deadline for the caller to recognize, and wraps any
+// other failure.
+func schemaApplyError(err error) error {
+	if _, ok := err.(*SchemaError); ok || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("schema apply error: %v", err)
+}
+
+type schemaStatement struct {
+	line int
+	sql  string
+}
+
+// splitSchema cuts a generated schema into statements with the lexer of the
+// target's dialect.
+func splitSchema(schema string, newReader func(io.Reader) *parser.StatementReader) ([]schemaStatement, error) {
+	r := newReader(strings.NewReader(schema))
+	var stmts []schemaStatement
+	for {
+		stmt, err := r.Next()
+		if err == io.EOF {
+			return stmts, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("schema split error: %v", err)
+		}
+		stmts = append(stmts, schemaStatement{line: r.Line(), sql: stmt})
+	}
+}
+
+// applyStatements executes stmts in order and stops at the first one the
+// target rejects, which is returned as a *SchemaError.
+func applyStatements(ctx context.Context, ex execer, stmts []schemaStatement, applied func(schemaStatement)) error {
+	for _, stmt := range stmts {
+		if _, err := ex.ExecContext(ctx, stmt.sql); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return &SchemaError{Line: stmt.line, Statement: stmt.sql, Err: err}
+		}
+		if applied != nil {
+			applied(stmt)
+		}
+	}
+	return nil
+}
+
+// applySchemaTx applies stmts inside one transaction, for targets with
+// transactional DDL: either the whole schema is created or nothing is.
+func applySchemaTx(ctx context.Context, conn *sql.DB, stmts []schemaStatement) error {
+	txn, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	if err := applyStatements(ctx, txn, stmts, nil); err != nil {
+		return err
+	}
+	return txn.Commit()
+}
+
+// createdTable returns the table a plain CREATE TABLE makes. Statements with
+// IF NOT EXISTS are skipped: the table may have existed before.
+func createdTable(stmt string) (string, bool) {
+	reCreate := regexp.MustCompile(`(?is)^CREATE\s+TABLE\s+(.+?)\s*\(`)
+	m := reCreate.FindStringSubmatch(stmt)
+	if m == nil || strings.HasPrefix(strings.ToUpper(m[1]), "IF NOT EXISTS") {
+		return "", false
+	}
+	return m[1], true
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"bigdataimporter/internal/parser"
+)
+
+func TestSchemaApplyError(t *testing.T) {
+	schemaErr := &SchemaError{Line: 3, Statement: "CREATE TABLE t (\n  id int\n)", Err: errors.New("syntax error")}
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+		kept bool
+	}{
+		{"schema error", schemaErr, "line 3: syntax error (statement: CREATE TABLE t ( ...)", true},
+		{"cancelled", context.Canceled, "context canceled", true},
+		{"deadline", context.DeadlineExceeded, "context deadline exceeded", true},
+		{"wrapped cancellation", fmt.Errorf("begin: %w", context.Canceled), "begin: context canceled", true},
+		{"other", errors.New("connection reset"), "schema apply error: connection reset", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := schemaApplyError(tt.err)
+			if got.Error() != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if (got == tt.err) != tt.kept {
+				t.Errorf("kept as is: got %v, want %v", got == tt.err, tt.kept)
+			}
+		})
+	}
+}
+
+// TestApplySchemaTx checks that a rejected statement leaves no table behind
+// and is reported with its line in the schema.
+func TestApplySchemaTx(t *testing.T) {
+	conn := openSQLite(t)
+	stmts, err := splitSchema("CREATE TABLE a (id INTEGER);\n\n-- second\nCREATE TABLE b (id INTEGER,);\n", parser.NewSQLiteStatementReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = applySchemaTx(context.Background(), conn, stmts)
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) {
+		t.Fatalf("got %v, want a *SchemaError", err)
+	}
+	if schemaErr.Line != 4 || schemaErr.Statement != "CREATE TABLE b (id INTEGER,)" {
+		t.Errorf("got line %d statement %q", schemaErr.Line, schemaErr.Statement)
+	}
+
+	var n int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("got %d tables after the rollback, want 0", n)
+	}
+}
@@ -0,0 +1,200 @@
+package db
+
+import (
+	"bigdataimporter/internal/config"
+	"bigdataimporter/internal/generator"
+	"bigdataimporter/internal/parser"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteConnector writes into a standalone database file under results/,
+// named after the configured database.
+type SQLiteConnector struct {
+	Cfg   *config.Config
+	Types *generator.TypeMap // the mapping the schema was generated with
+}
+
+func (s *SQLiteConnector) Path() string {
+	name := "bigdataimporter"
+	if s.Cfg != nil && s.Cfg.Database.Name != "" {
+		name = s.Cfg.Database.Name
+	}
+	return filepath.Join("results", name+".db")
+}
+
+func (s *SQLiteConnector) Connect() (*sql.DB, error) {
+	if err := os.MkdirAll("results", 0777); err != nil {
+		return nil, fmt.Errorf("results folder error: %v", err)
+	}
+
+	db, err := sql.Open("sqlite", s.Path())
+	if err != nil {
+		return nil, fmt.Errorf("connection failed: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("ping failed: %v", err)
+	}
+
+	log.Printf("SQLite bağlantısı başarılı: %s", s.Path())
+	return db, nil
+}
+
+// ApplySchema runs the schema statement by statement in one transaction, so
+// a failure leaves no table behind.
+func (s *SQLiteConnector) ApplySchema(ctx context.Context, conn *sql.DB, schema string) error {
+	stmts, err := splitSchema(schema, parser.NewSQLiteStatementReader)
+	if err != nil {
+		return err
+	}
+	if err := applySchemaTx(ctx, conn, stmts); err != nil {
+		return schemaApplyError(err)
+	}
+	log.Printf("Schema başarıyla uygulandı (%s)", s.Path())
+	return nil
+}
+
+func (s *SQLiteConnector) ImportData(ctx context.Context, conn *sql.DB, tables []parser.ParsedTable, rows parser.RowSource) error {
+	logDir := "logs"
+	os.MkdirAll(logDir, 0755)
+	failedFile := filepath.Join(logDir, "failed_rows.log")
+
+	colTypes := sqliteColumnTypes(tables, s.Types)
+	imported := map[string]int64{}
+	failed := map[string]int64{}
+	progress := parser.ProgressFrom(ctx)
+
+	err := batchRows(ctx, rows, tables, batchSize(s.Cfg), func(b *rowBatch) {
+		types := colTypes[b.table]
+		if err := insertSQLiteRows(ctx, conn, b, types); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Insert failed for %s (%d rows), retrying row by row: %v", b.table, len(b.rows), err)
+			n, lost := retryRowByRow(ctx, conn, b, failedFile, beginTx, commitTx, func(ctx context.Context, ex execer, b *rowBatch) error {
+				return insertSQLiteBatch(ctx, ex, b, types)
+			})
+			imported[b.table] += n
+			failed[b.table] += lost
+			progress.Imported(b.table, int(n))
+			return
+		}
+		imported[b.table] += int64(len(b.rows))
+		progress.Imported(b.table, len(b.rows))
+	})
+
+	for _, t := range tables {
+		if n := imported[t.TableName]; n > 0 {
+			log.Printf("%s data imported successfully (%d/%d rows)", t.TableName, n, t.RowCount)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return rejectedRowsError(tables, failed, failedFile)
+}
+
+// DryRun applies the schema and loads every row inside one transaction that
+// is rolled back at the end; SQLite DDL is transactional as well.
+func (s *SQLiteConnector) DryRun(ctx context.Context, conn *sql.DB, schema string, tables []parser.ParsedTable, rows parser.RowSource) (*DryRunReport, error) {
+	txn, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Rollback()
+
+	report := newDryRunReport("sqlite", tables)
+	stmts, err := splitSchema(schema, parser.NewSQLiteStatementReader)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyStatements(ctx, txn, stmts, nil); err != nil {
+		return report.schemaFailed(err)
+	}
+
+	colTypes := sqliteColumnTypes(tables, s.Types)
+	err = dryRunLoad(ctx, txn, report, tables, rows, batchSize(s.Cfg), func(ctx context.Context, ex execer, b *rowBatch) error {
+		return insertSQLiteBatch(ctx, ex, b, colTypes[b.table])
+	})
+	report.finish()
+	return report, err
+}
+
+// insertSQLiteRows writes one batch inside a single transaction; SQLite is
+// orders of magnitude faster this way than with autocommit per row.
+func insertSQLiteRows(ctx context.Context, conn *sql.DB, b *rowBatch, types map[string]string) error {
+	txn, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	if err := insertSQLiteBatch(ctx, txn, b, types); err != nil {
+		return err
+	}
+	return txn.Commit()
+}
+
+func insertSQLiteBatch(ctx context.Context, ex execer, b *rowBatch, types map[string]string) error {
+	quoted := make([]string, len(b.columns))
+	for i, c := range b.columns {
+		quoted[i] = generator.QuoteSQLiteIdent(c)
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		generator.QuoteSQLiteIdent(b.table),
+		strings.Join(quoted, ", "),
+		strings.TrimSuffix(strings.Repeat("?, ", len(b.columns)), ", "))
+
+	stmt, err := ex.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	args := make([]interface{}, len(b.columns))
+	for _, row := range b.rows {
+		for i, col := range b.columns {
+			args[i] = nil
+			if i < len(row) {
+				args[i] = sqliteValue(row[i], types[col])
+			}
+		}
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sqliteColumnTypes(tables []parser.ParsedTable, typeMap *generator.TypeMap) map[string]map[string]string {
+	types := map[string]map[string]string{}
+	for _, t := range tables {
+		cols := map[string]string{}
+		for _, f := range t.Fields {
+			cols[f.Name] = strings.ToUpper(typeMap.Column(generator.DialectSQLite, t.TableName, f.Name, f.ColumnType(), f.AutoIncrement))
+		}
+		types[t.TableName] = cols
+	}
+	return types
+}
+
+func sqliteValue(v interface{}, sqliteType string) interface{} {
+	switch val := v.(type) {
+	case []byte:
+		if sqliteType != "BLOB" {
+			return string(val)
+		}
+		return val
+	case parser.Number:
+		return string(val)
+	default:
+		return v
+	}
+}
@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bigdataimporter/internal/config"
+	"bigdataimporter/internal/generator"
+	"bigdataimporter/internal/parser"
+)
+
+// staticRows is a RowSource replaying fixed batches.
+type staticRows []parser.Insert
+
+func (r staticRows) Each(ctx context.Context, fn func(parser.Insert) error) error {
+	for _, ins := range r {
+		if err := fn(ins); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chdir moves the test into a temporary directory, where the connectors
+// write logs/ and results/.
+func chdir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return dir
+}
+
+func openSQLite(t *testing.T, schema ...string) *sql.DB {
+	t.Helper()
+	conn, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	for _, stmt := range schema {
+		if _, err := conn.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return conn
+}
+
+func TestSQLiteImportData(t *testing.T) {
+	users := parser.ParsedTable{TableName: "users", Fields: []parser.Field{{Name: "id", Type: "int"}, {Name: "name", Type: "varchar(20)"}}}
+	cols := []string{"id", "name"}
+
+	tests := []struct {
+		name      string
+		rows      [][]interface{}
+		wantErr   string
+		wantCount int
+	}{
+		{
+			name:      "all rows",
+			rows:      [][]interface{}{{int64(1), "a"}, {int64(2), "b"}, {int64(3), "c"}},
+			wantCount: 3,
+		},
+		{
+			name:      "rejected rows",
+			rows:      [][]interface{}{{int64(1), "a"}, {int64(2), nil}, {int64(3), "c"}, {int64(3), "d"}, {int64(5), "e"}},
+			wantErr:   "rows rejected: users (2 rows); see logs/failed_rows.log",
+			wantCount: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := chdir(t)
+			conn := openSQLite(t, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL)")
+			s := &SQLiteConnector{Cfg: &config.Config{Import: config.ImportConfig{BatchSize: 2}}}
+
+			tables := []parser.ParsedTable{users}
+			tables[0].RowCount = int64(len(tt.rows))
+			err := s.ImportData(context.Background(), conn, tables, staticRows{{Table: "users", Columns: cols, Rows: tt.rows}})
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("ImportData: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+
+			var n int
+			if err := conn.QueryRow("SELECT COUNT(*) FROM users").Scan(&n); err != nil {
+				t.Fatal(err)
+			}
+			if n != tt.wantCount {
+				t.Errorf("got %d rows, want %d", n, tt.wantCount)
+			}
+
+			logged, _ := os.ReadFile(filepath.Join(dir, "logs", "failed_rows.log"))
+			if got := strings.Count(string(logged), "-- users:"); tt.wantErr != "" && got != 2 {
+				t.Errorf("got %d logged rows, want 2:\n%s", got, logged)
+			}
+		})
+	}
+}
+
+// TestSQLiteApplyGeneratedSchema applies the DDL of the SQLite generator and
+// checks that its constraints hold in the database.
+func TestSQLiteApplyGeneratedSchema(t *testing.T) {
+	schema, err := generator.GenerateSQLiteSchema([]generator.Table{{
+		TableName:  "users",
+		PrimaryKey: []string{"id"},
+		Fields: []generator.Field{
+			{Name: "id", Type: "int", PrimaryKey: true, AutoIncrement: true},
+			{Name: "email", Type: "varchar(255)", Unique: true},
+			{Name: "status", Type: "enum('active','banned')", Default: "'active'"},
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := openSQLite(t)
+	s := &SQLiteConnector{}
+	if err := s.ApplySchema(context.Background(), conn, schema); err != nil {
+		t.Fatalf("ApplySchema: %v\n%s", err, schema)
+	}
+
+	if _, err := conn.Exec(`INSERT INTO users (email) VALUES ('a@example.com')`); err != nil {
+		t.Fatal(err)
+	}
+	var id int
+	var status string
+	if err := conn.QueryRow(`SELECT id, status FROM users`).Scan(&id, &status); err != nil {
+		t.Fatal(err)
+	}
+	if id != 1 || status != "active" {
+		t.Errorf("got id %d status %q, want 1 active", id, status)
+	}
+	for _, stmt := range []string{
+		`INSERT INTO users (email) VALUES ('a@example.com')`,
+		`INSERT INTO users (email, status) VALUES ('b@example.com', 'gone')`,
+	} {
+		if _, err := conn.Exec(stmt); err == nil {
+			t.Errorf("%s: accepted", stmt)
+		}
+	}
+}
@@ -3,66 +3,181 @@ package executor
 import (
 	"bigdataimporter/internal/config"
 	"bigdataimporter/internal/db"
+	"bigdataimporter/internal/generator"
 	"bigdataimporter/internal/parser"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 )
 
 type Job struct {
-	ID       string
-	FilePath string
-	Target   string
+	ID         string
+	FilePath   string
+	Target     string
+	ConfigPath string             // defaults to the Docker image's /app/config.yaml
+	IfExists   string             // policy for existing tables, see generator.IfExistsFail
+	Types      *generator.TypeMap // the mapping the schema was generated with
+
+	// Preflight, when set, is told which tables existed before the import.
+	Preflight func([]db.ExistingTable)
 }
 
-func Run(job Job, tables []parser.ParsedTable) {
-	wd, _ := os.Getwd()
-	log.Printf("Current working directory: %s", wd)
-	log.Printf("Executor started: %s -> %s", job.FilePath, job.Target)
+func Run(ctx context.Context, job Job, tables []parser.ParsedTable, rows parser.RowSource) error {
+	connector, conn, content, err := open(job)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
 
-	cfg, err := config.LoadConfig("/app/config.yaml")
+	tables, rows, _, err = preflight(ctx, job, connector, conn, tables, rows)
 	if err != nil {
-		log.Printf("Config load error: %v", err)
-		return
+		return err
 	}
 
-	connector := db.SelectConnector(job.Target, cfg)
-	if connector == nil {
-		log.Printf("Unsupported target: %s", job.Target)
-		return
+	if err := connector.ApplySchema(ctx, conn, content); err != nil {
+		var schemaErr *db.SchemaError
+		if errors.As(err, &schemaErr) {
+			log.Printf("Schema apply error at %s:%d, rolled back: %v\n%s", job.FilePath, schemaErr.Line, schemaErr.Err, schemaErr.Statement)
+			return fmt.Errorf("schema apply error at %s:%d: %v (statement: %s)", job.FilePath, schemaErr.Line, schemaErr.Err, schemaErr.Summary())
+		}
+		log.Printf("Schema apply error: %v", err)
+		return err
 	}
 
-	conn, err := connector.Connect()
+	log.Printf("Schema successfully applied: %s", job.FilePath)
+
+	importErr := connector.ImportData(ctx, conn, tables, rows)
+	if importErr != nil {
+		log.Printf("Data import error: %v", importErr)
+		importErr = fmt.Errorf("data import error: %v", importErr)
+	} else {
+		log.Printf("Data import completed successfully.")
+	}
+
+	return importErr
+}
+
+// DryRun validates the job against its target without leaving anything
+// behind and returns what the import would have done.
+func DryRun(ctx context.Context, job Job, tables []parser.ParsedTable, rows parser.RowSource) (*db.DryRunReport, error) {
+	connector, conn, content, err := open(job)
 	if err != nil {
-		log.Printf("DB connection failed: %v", err)
-		return
+		return nil, err
 	}
 	defer conn.Close()
 
-	content, err := os.ReadFile(filepath.Clean(job.FilePath))
+	runner, ok := connector.(db.DryRunner)
+	if !ok {
+		return nil, fmt.Errorf("dry run not supported for target: %s", job.Target)
+	}
+
+	tables, rows, existing, err := preflight(ctx, job, connector, conn, tables, rows)
 	if err != nil {
-		log.Printf("SQL file read error: %v", err)
-		return
+		return nil, err
 	}
 
-	_, _ = conn.Exec(`SET session_replication_role = replica;`)
-	log.Println("Foreign key checks disabled temporarily")
+	log.Printf("Dry run started: %s -> %s", job.FilePath, job.Target)
+	report, err := runner.DryRun(ctx, conn, content, tables, rows)
+	if report != nil {
+		report.Existing = existing
+	}
+	if err != nil {
+		log.Printf("Dry run error: %v", err)
+		return report, fmt.Errorf("dry run error: %v", err)
+	}
+	log.Printf("Dry run finished: %d/%d rows valid, schema ok: %v", report.ValidRows, report.TotalRows, report.SchemaError == "")
+	return report, nil
+}
 
-	if err := connector.ApplySchema(conn, string(content)); err != nil {
-		log.Printf("Schema apply error: %v", err)
-		_, _ = conn.Exec(`SET session_replication_role = DEFAULT;`)
-		return
+// preflight looks for tables of the import that already exist in the target
+// and applies the job's if_exists policy: fail stops here, skip leaves those
+// tables out of the load. The others are handled by the generated schema.
+func preflight(ctx context.Context, job Job, connector db.Connector, conn *sql.DB, tables []parser.ParsedTable, rows parser.RowSource) ([]parser.ParsedTable, parser.RowSource, []db.ExistingTable, error) {
+	pf, ok := connector.(db.Preflighter)
+	if !ok {
+		if job.IfExists != "" {
+			return nil, nil, nil, fmt.Errorf("if_exists not supported for target: %s", job.Target)
+		}
+		return tables, rows, nil, nil
 	}
 
-	log.Printf("Schema successfully applied: %s", job.FilePath)
+	existing, err := pf.Preflight(ctx, conn, tables)
+	if err != nil {
+		log.Printf("Preflight error: %v", err)
+		return nil, nil, nil, err
+	}
+	for _, e := range existing {
+		log.Printf("Tablo zaten mevcut: %s (%d satır)", e.Table, e.Rows)
+	}
+	if job.Preflight != nil {
+		job.Preflight(existing)
+	}
 
-	if err := connector.ImportData(conn, tables); err != nil {
-		log.Printf("Data import error: %v", err)
-	} else {
-		log.Printf("Data import completed successfully.")
+	if err := db.CheckExisting(job.IfExists, existing); err != nil {
+		return nil, nil, existing, err
 	}
+	tables, rows = db.SkipExisting(job.IfExists, existing, tables, rows)
+	return tables, rows, existing, nil
+}
 
-	_, _ = conn.Exec(`SET session_replication_role = DEFAULT;`)
-	log.Println("Foreign key checks re-enabled (after import)")
+// LoadConfig loads the config file of a job; an empty path is the Docker
+// image's /app/config.yaml.
+func LoadConfig(path string) (*config.Config, error) {
+	if path == "" {
+		path = "/app/config.yaml"
+	}
+	return config.LoadConfig(path)
+}
 
+// TypeMap builds the type mapping for input of the given format from the
+// type_mappings and postgres options of the config file. Without a config
+// file only the built-in mapping is used.
+func TypeMap(configPath, source string) (*generator.TypeMap, error) {
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		log.Printf("Type mappings not loaded, using built-in types: %v", err)
+		return nil, nil
+	}
+	types, err := generator.NewTypeMap(source, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("config error: %v", err)
+	}
+	return types, nil
+}
+
+// open loads the config, connects to the job's target and reads the schema
+// to apply.
+func open(job Job) (db.Connector, *sql.DB, string, error) {
+	wd, _ := os.Getwd()
+	log.Printf("Current working directory: %s", wd)
+	log.Printf("Executor started: %s -> %s", job.FilePath, job.Target)
+
+	cfg, err := LoadConfig(job.ConfigPath)
+	if err != nil {
+		log.Printf("Config load error: %v", err)
+		return nil, nil, "", fmt.Errorf("config load error: %v", err)
+	}
+
+	connector := db.SelectConnector(job.Target, cfg, job.Types)
+	if connector == nil {
+		log.Printf("Unsupported target: %s", job.Target)
+		return nil, nil, "", fmt.Errorf("unsupported target: %s", job.Target)
+	}
+
+	content, err := os.ReadFile(filepath.Clean(job.FilePath))
+	if err != nil {
+		log.Printf("SQL file read error: %v", err)
+		return nil, nil, "", fmt.Errorf("sql file read error: %v", err)
+	}
+
+	conn, err := connector.Connect()
+	if err != nil {
+		log.Printf("DB connection failed: %v", err)
+		return nil, nil, "", fmt.Errorf("db connection failed: %v", err)
+	}
+	return connector, conn, string(content), nil
 }
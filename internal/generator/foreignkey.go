@@ -0,0 +1,59 @@
+package generator
+
+import (
+	"bigdataimporter/internal/parser"
+	"fmt"
+	"strings"
+)
+
+// tableForeignKeys returns the foreign keys of a table: its own list, then
+// a single-column key for every field with a ForeignKey that the list does
+// not cover, as tables built by hand only set it on the fields.
+func tableForeignKeys(t Table) []parser.ForeignKey {
+	fks := append([]parser.ForeignKey(nil), t.ForeignKeys...)
+	for _, f := range t.Fields {
+		if f.ForeignKey == nil || f.ForeignKey.ReferencedTable == "" || f.ForeignKey.ReferencedField == "" {
+			continue
+		}
+		covered := false
+		for _, fk := range fks {
+			covered = covered || (len(fk.Columns) == 1 && strings.EqualFold(fk.Columns[0], f.Name))
+		}
+		if !covered {
+			fks = append(fks, parser.ForeignKey{
+				Columns:           []string{f.Name},
+				ReferencedTable:   f.ForeignKey.ReferencedTable,
+				ReferencedColumns: []string{f.ForeignKey.ReferencedField},
+			})
+		}
+	}
+	return fks
+}
+
+// foreignKeyName returns the constraint name of a foreign key, made up from
+// the table and columns when the source had none.
+func foreignKeyName(table string, fk parser.ForeignKey) string {
+	if fk.Name != "" {
+		return fk.Name
+	}
+	return fmt.Sprintf("fk_%s_%s", table, strings.Join(fk.Columns, "_"))
+}
+
+// foreignKeyActions spells the referential actions of a foreign key, and
+// its deferrability where the target has it.
+func foreignKeyActions(fk parser.ForeignKey, deferrable bool) string {
+	var s string
+	if fk.OnDelete != "" {
+		s += " ON DELETE " + fk.OnDelete
+	}
+	if fk.OnUpdate != "" {
+		s += " ON UPDATE " + fk.OnUpdate
+	}
+	if deferrable && fk.Deferrable {
+		s += " DEFERRABLE"
+		if fk.InitiallyDeferred {
+			s += " INITIALLY DEFERRED"
+		}
+	}
+	return s
+}
@@ -0,0 +1,65 @@
+package generator
+
+import (
+	"bigdataimporter/internal/parser"
+	"fmt"
+	"strings"
+)
+
+// tableIndexes returns the keys of a table: its own list, then a
+// single-column key for every field flagged Index or Unique that the list
+// does not cover, as tables built by hand only carry the flags.
+func tableIndexes(t Table) []parser.Index {
+	indexes := append([]parser.Index(nil), t.Indexes...)
+	for _, f := range t.Fields {
+		if !f.Index && !f.Unique {
+			continue
+		}
+		covered := false
+		for _, ix := range indexes {
+			for _, c := range ix.Columns {
+				if strings.EqualFold(c.Name, f.Name) && (!f.Unique || (ix.Unique && len(ix.Columns) == 1)) {
+					covered = true
+				}
+			}
+		}
+		if !covered {
+			indexes = append(indexes, parser.Index{
+				Name:    fmt.Sprintf("idx_%s_%s", t.TableName, f.Name),
+				Columns: []parser.IndexColumn{{Name: f.Name}},
+				Unique:  f.Unique,
+			})
+		}
+	}
+	return indexes
+}
+
+// indexName returns the name of a key, made up from the table and columns
+// when the source had none.
+func indexName(table string, ix parser.Index) string {
+	if ix.Name != "" {
+		return ix.Name
+	}
+	return fmt.Sprintf("idx_%s_%s", table, strings.Join(ix.ColumnNames(), "_"))
+}
+
+// schemaIndexName returns indexName unique within the schema, for targets
+// where index names are not scoped to their table. MySQL dumps often reuse
+// a key name such as "name" across tables.
+func schemaIndexName(used map[string]bool, table string, ix parser.Index) string {
+	return schemaName(used, table, indexName(table, ix))
+}
+
+// schemaName returns name, prefixed with the table and then numbered if it
+// is taken, and marks the result taken.
+func schemaName(used map[string]bool, table, name string) string {
+	unique := name
+	if used[strings.ToLower(unique)] {
+		unique = table + "_" + name
+	}
+	for n := 2; used[strings.ToLower(unique)]; n++ {
+		unique = fmt.Sprintf("%s_%s_%d", table, name, n)
+	}
+	used[strings.ToLower(unique)] = true
+	return unique
+}
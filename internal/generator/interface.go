@@ -1,6 +1,17 @@
 package generator
 
+import (
+	"bigdataimporter/internal/parser"
+	"context"
+)
+
 type Generator interface {
 	GenerateSchema(tables []Table) (string, error)
 	ImportData(tables []Table) error
 }
+
+// DocumentWriter is implemented by targets whose data is exported as files
+// instead of being loaded through a db.Connector.
+type DocumentWriter interface {
+	WriteDocuments(ctx context.Context, dir string, tables []Table, rows parser.RowSource) error
+}
@@ -1,11 +1,541 @@
 package generator
 
-type MongoGenerator struct{}
+import (
+	"bigdataimporter/internal/parser"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MongoGenerator turns tables into MongoDB collections. The schema is a
+// mongosh script; the data is written as Extended JSON documents, one
+// mongoimport-ready file per collection.
+type MongoGenerator struct {
+	// EmbedChildren stores the rows of a one-to-many child table as an array
+	// inside the parent document instead of a collection of its own.
+	EmbedChildren bool
+
+	// Types overrides the BSON types of columns; built-in mapping when nil.
+	Types *TypeMap
+}
+
+type mongoEmbed struct {
+	Parent      string
+	Child       string
+	Field       string // foreign key column in the child
+	ParentField string // referenced column in the parent
+}
+
+// mongoDoc is a JSON object that keeps its keys in insertion order, which
+// matters for compound index keys and keeps documents readable.
+type mongoDoc []mongoElem
+
+type mongoElem struct {
+	Key   string
+	Value interface{}
+}
+
+func (d mongoDoc) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, e := range d {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(e.Key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+type mongoSchema struct {
+	BSONType   interface{}             `json:"bsonType"`
+	Required   []string                `json:"required,omitempty"`
+	Properties map[string]*mongoSchema `json:"properties,omitempty"`
+	Items      *mongoSchema            `json:"items,omitempty"`
+	MaxLength  int                     `json:"maxLength,omitempty"`
+	Enum       []interface{}           `json:"enum,omitempty"`
+}
+
+type mongoIndex struct {
+	Key    mongoDoc `json:"key"`
+	Name   string   `json:"name"`
+	Unique bool     `json:"unique,omitempty"`
+}
+
+func mysqlBaseType(mysqlType string) string {
+	t := strings.ToLower(strings.TrimSpace(mysqlType))
+	if i := strings.IndexAny(t, "( "); i >= 0 {
+		t = t[:i]
+	}
+	return t
+}
+
+// MySQLToBSONType maps a MySQL column type to the BSON type its values are
+// stored as.
+func MySQLToBSONType(mysqlType string) string {
+	t := strings.ToLower(mysqlType)
+	switch mysqlBaseType(t) {
+	case "bool", "boolean":
+		return "bool"
+	case "tinyint", "bit":
+		if strings.HasPrefix(t, "tinyint(1)") || strings.HasPrefix(t, "bit(1)") {
+			return "bool"
+		}
+		return "long"
+	case "smallint", "mediumint", "int", "integer", "bigint", "serial":
+		return "long"
+	case "decimal", "numeric", "dec", "fixed":
+		return "decimal"
+	case "float", "double", "real":
+		return "double"
+	case "date", "datetime", "timestamp":
+		return "date"
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob":
+		return "binData"
+	case "set":
+		return "array"
+	default:
+		return "string"
+	}
+}
+
+// plan decides which child tables get embedded into which parent. Only one
+// level is embedded so a document never nests its own ancestors.
+func (m *MongoGenerator) plan(tables []Table) (map[string][]mongoEmbed, map[string]mongoEmbed) {
+	embeds := map[string][]mongoEmbed{}
+	embedded := map[string]mongoEmbed{}
+	if !m.EmbedChildren {
+		return embeds, embedded
+	}
+
+	known := map[string]bool{}
+	for _, t := range tables {
+		known[t.TableName] = true
+	}
+
+	for _, t := range tables {
+		for _, f := range t.Fields {
+			if f.ForeignKey == nil {
+				continue
+			}
+			parent := f.ForeignKey.ReferencedTable
+			_, childDone := embedded[t.TableName]
+			_, parentEmbedded := embedded[parent]
+			if !known[parent] || parent == t.TableName || childDone || parentEmbedded || len(embeds[t.TableName]) > 0 {
+				continue
+			}
+			e := mongoEmbed{Parent: parent, Child: t.TableName, Field: f.Name, ParentField: f.ForeignKey.ReferencedField}
+			embeds[parent] = append(embeds[parent], e)
+			embedded[t.TableName] = e
+		}
+	}
+	return embeds, embedded
+}
+
+func mongoTableSchema(t Table, types *TypeMap) *mongoSchema {
+	schema := &mongoSchema{BSONType: "object", Properties: map[string]*mongoSchema{}}
+	for _, f := range t.Fields {
+		ct := f.ColumnType()
+		bsonType, builtin := types.lookup(DialectMongo, t.TableName, f.Name, ct, f.AutoIncrement)
+		prop := &mongoSchema{BSONType: bsonType}
+		// Zero dates and dates that do not parse are stored as null, see
+		// mongoValue.
+		if f.Nullable || bsonType == "date" {
+			prop.BSONType = []string{bsonType, "null"}
+		}
+		if !f.Nullable {
+			schema.Required = append(schema.Required, f.Name)
+		}
+		if bsonType == "string" && (ct.Base == "char" || ct.Base == "varchar") {
+			prop.MaxLength = ct.Length
+		}
+		if builtin && len(ct.Values) > 0 {
+			members := make([]interface{}, len(ct.Values))
+			for i, v := range ct.Values {
+				members[i] = v
+			}
+			switch ct.Base {
+			case "enum":
+				// enum is checked against the value itself, null included.
+				if f.Nullable {
+					members = append(members, nil)
+				}
+				prop.Enum = members
+			case "set":
+				prop.Items = &mongoSchema{BSONType: "string", Enum: members}
+			}
+		}
+		schema.Properties[f.Name] = prop
+	}
+	return schema
+}
+
+func mongoTableIndexes(t Table, prefix string) []mongoIndex {
+	var indexes []mongoIndex
+	if len(t.PrimaryKey) > 0 {
+		key := mongoDoc{}
+		for _, pk := range t.PrimaryKey {
+			key = append(key, mongoElem{prefix + pk, 1})
+		}
+		indexes = append(indexes, mongoIndex{Key: key, Name: fmt.Sprintf("pk_%s", t.TableName), Unique: prefix == ""})
+	}
+	text := false
+	for _, ix := range tableIndexes(t) {
+		var order interface{} = 1
+		switch ix.Type {
+		case parser.IndexSpatial:
+			// Spatial values are stored as WKB, not GeoJSON.
+			continue
+		case parser.IndexFulltext:
+			// A collection can have one text index only.
+			if text {
+				continue
+			}
+			text, order = true, "text"
+		}
+		key := mongoDoc{}
+		for _, c := range ix.Columns {
+			key = append(key, mongoElem{prefix + c.Name, order})
+		}
+		name := indexName(t.TableName, ix)
+		if prefix != "" && ix.Name != "" {
+			// Keys of embedded tables share the parent's collection.
+			name = t.TableName + "_" + name
+		}
+		indexes = append(indexes, mongoIndex{
+			Key:    key,
+			Name:   name,
+			Unique: ix.Unique && prefix == "",
+		})
+	}
+	return indexes
+}
 
 func (m *MongoGenerator) GenerateSchema(tables []Table) (string, error) {
-	return "// Mongo schema generation not implemented yet\n", nil
+	var sb strings.Builder
+	embeds, embedded := m.plan(tables)
+
+	byName := map[string]Table{}
+	for _, t := range tables {
+		byName[t.TableName] = t
+	}
+
+	sb.WriteString("// MongoDB schema generated by bigdata-importer\n")
+	sb.WriteString("// Load the data with: mongoimport --collection <name> --file <name>.json\n\n")
+
+	for _, t := range tables {
+		if t.TableName == "" {
+			continue
+		}
+		if _, ok := embedded[t.TableName]; ok {
+			continue
+		}
+
+		schema := mongoTableSchema(t, m.Types)
+		indexes := mongoTableIndexes(t, "")
+		for _, e := range embeds[t.TableName] {
+			child := byName[e.Child]
+			schema.Properties[e.Child] = &mongoSchema{BSONType: "array", Items: mongoTableSchema(child, m.Types)}
+			indexes = append(indexes, mongoTableIndexes(child, e.Child+".")...)
+		}
+
+		options := mongoDoc{
+			{"validator", mongoDoc{{"$jsonSchema", schema}}},
+			{"validationLevel", "moderate"},
+		}
+		optJSON, err := json.MarshalIndent(options, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		name, _ := json.Marshal(t.TableName)
+
+		sb.WriteString(fmt.Sprintf("// Collection: %s\n", t.TableName))
+		sb.WriteString(fmt.Sprintf("db.createCollection(%s, %s);\n", name, optJSON))
+		if len(indexes) > 0 {
+			idxJSON, err := json.MarshalIndent(indexes, "", "  ")
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(fmt.Sprintf("db.getCollection(%s).createIndexes(%s);\n", name, idxJSON))
+		}
+		sb.WriteString("\n")
+	}
+
+	return sb.String(), nil
 }
 
 func (m *MongoGenerator) ImportData(tables []Table) error {
 	return nil
 }
+
+// WriteDocuments converts the rows of every table into Extended JSON
+// documents under dir, one <collection>.json file per collection. Embedded
+// child rows are collected in a first pass over the data, so only they are
+// kept in memory; parent documents are streamed.
+func (m *MongoGenerator) WriteDocuments(ctx context.Context, dir string, tables []Table, rows parser.RowSource) error {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return fmt.Errorf("%s folder error: %v", dir, err)
+	}
+
+	types := map[string]map[string]string{}
+	for _, t := range tables {
+		types[t.TableName] = mongoFieldTypes(t, m.Types)
+	}
+	embeds, embedded := m.plan(tables)
+
+	children := map[string]map[string][]mongoDoc{}
+	if len(embedded) > 0 {
+		err := rows.Each(ctx, func(ins parser.Insert) error {
+			e, ok := embedded[ins.Table]
+			if !ok {
+				return nil
+			}
+			idx := columnIndex(ins.Columns, e.Field)
+			if idx < 0 {
+				return nil
+			}
+			if children[ins.Table] == nil {
+				children[ins.Table] = map[string][]mongoDoc{}
+			}
+			for _, row := range ins.Rows {
+				if idx >= len(row) || row[idx] == nil {
+					continue
+				}
+				key := fmt.Sprint(row[idx])
+				children[ins.Table][key] = append(children[ins.Table][key], mongoDocument(types[ins.Table], ins.Columns, row))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	files := map[string]*os.File{}
+	writers := map[string]*bufio.Writer{}
+	counts := map[string]int64{}
+
+	progress := parser.ProgressFrom(ctx)
+	err := rows.Each(ctx, func(ins parser.Insert) error {
+		colTypes, ok := types[ins.Table]
+		if !ok {
+			return nil
+		}
+		if _, ok := embedded[ins.Table]; ok {
+			return nil
+		}
+
+		w := writers[ins.Table]
+		if w == nil {
+			f, err := os.Create(filepath.Join(dir, ins.Table+".json"))
+			if err != nil {
+				return err
+			}
+			files[ins.Table] = f
+			w = bufio.NewWriter(f)
+			writers[ins.Table] = w
+		}
+
+		for _, row := range ins.Rows {
+			doc := mongoDocument(colTypes, ins.Columns, row)
+			for _, e := range embeds[ins.Table] {
+				items := []mongoDoc{}
+				if idx := columnIndex(ins.Columns, e.ParentField); idx >= 0 && idx < len(row) && row[idx] != nil {
+					if found := children[e.Child][fmt.Sprint(row[idx])]; found != nil {
+						items = found
+					}
+				}
+				doc = append(doc, mongoElem{e.Child, items})
+			}
+			b, err := json.Marshal(doc)
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(b); err != nil {
+				return err
+			}
+			if err := w.WriteByte('\n'); err != nil {
+				return err
+			}
+			counts[ins.Table]++
+		}
+		progress.Imported(ins.Table, len(ins.Rows))
+		return nil
+	})
+
+	// Every file is flushed and closed even after a failure; the first
+	// error is the one reported.
+	for _, t := range tables {
+		f, ok := files[t.TableName]
+		if !ok {
+			continue
+		}
+		if ferr := writers[t.TableName].Flush(); err == nil {
+			err = ferr
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+
+	for _, t := range tables {
+		if n := counts[t.TableName]; n > 0 {
+			log.Printf("%s: %d documents written", t.TableName, n)
+		}
+	}
+	return err
+}
+
+func columnIndex(columns []string, name string) int {
+	for i, c := range columns {
+		if strings.EqualFold(c, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func mongoFieldTypes(t Table, typeMap *TypeMap) map[string]string {
+	types := map[string]string{}
+	for _, f := range t.Fields {
+		types[f.Name] = typeMap.Column(DialectMongo, t.TableName, f.Name, f.ColumnType(), f.AutoIncrement)
+	}
+	return types
+}
+
+func mongoDocument(types map[string]string, columns []string, row []interface{}) mongoDoc {
+	doc := make(mongoDoc, 0, len(columns))
+	for i, col := range columns {
+		var v interface{}
+		if i < len(row) {
+			v = row[i]
+		}
+		doc = append(doc, mongoElem{col, mongoValue(v, types[col])})
+	}
+	return doc
+}
+
+// mongoValue renders a decoded value as Extended JSON v2 for the BSON type of
+// its column.
+func mongoValue(v interface{}, bsonType string) interface{} {
+	if v == nil {
+		return nil
+	}
+
+	switch bsonType {
+	case "bool":
+		switch val := v.(type) {
+		case int64:
+			return val != 0
+		case string:
+			return val == "1" || strings.EqualFold(val, "true")
+		}
+	case "long":
+		switch val := v.(type) {
+		case int64:
+			return map[string]string{"$numberLong": strconv.FormatInt(val, 10)}
+		case bool:
+			if val {
+				return map[string]string{"$numberLong": "1"}
+			}
+			return map[string]string{"$numberLong": "0"}
+		case parser.Number:
+			// Integers beyond int64 are kept as written; the server
+			// rejects them rather than storing another type.
+			return map[string]string{"$numberLong": string(val)}
+		case string:
+			if _, err := strconv.ParseInt(val, 10, 64); err == nil {
+				return map[string]string{"$numberLong": val}
+			}
+		}
+	case "decimal":
+		switch val := v.(type) {
+		case int64:
+			return map[string]string{"$numberDecimal": strconv.FormatInt(val, 10)}
+		case parser.Number:
+			return map[string]string{"$numberDecimal": string(val)}
+		case string:
+			return map[string]string{"$numberDecimal": val}
+		}
+	case "double":
+		switch val := v.(type) {
+		case int64:
+			return map[string]string{"$numberDouble": strconv.FormatInt(val, 10)}
+		case parser.Number:
+			return map[string]string{"$numberDouble": string(val)}
+		}
+	case "date":
+		if s, ok := v.(string); ok {
+			// MySQL zero dates, and values that are no date at all, become
+			// null; the validator allows null for every date column.
+			for _, layout := range []string{"2006-01-02 15:04:05.999999", "2006-01-02 15:04:05.999999Z07", "2006-01-02T15:04:05.999999Z07:00", "2006-01-02"} {
+				if t, err := time.Parse(layout, s); err == nil {
+					return map[string]string{"$date": t.UTC().Format("2006-01-02T15:04:05.000Z")}
+				}
+			}
+			return nil
+		}
+	case "binData":
+		var b []byte
+		switch val := v.(type) {
+		case []byte:
+			b = val
+		case string:
+			b = []byte(val)
+		}
+		if b != nil {
+			return map[string]interface{}{"$binary": map[string]string{
+				"base64":  base64.StdEncoding.EncodeToString(b),
+				"subType": "00",
+			}}
+		}
+	case "array":
+		// SET values are stored as the list of their members.
+		var set string
+		switch val := v.(type) {
+		case []byte:
+			set = string(val)
+		case string:
+			set = val
+		default:
+			return v
+		}
+		members := []string{}
+		if set != "" {
+			members = strings.Split(set, ",")
+		}
+		return members
+	case "string":
+		switch val := v.(type) {
+		case []byte:
+			return string(val)
+		case int64:
+			return strconv.FormatInt(val, 10)
+		case parser.Number:
+			return string(val)
+		case bool:
+			return strconv.FormatBool(val)
+		}
+	}
+	return v
+}
@@ -0,0 +1,179 @@
+package generator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"bigdataimporter/internal/parser"
+)
+
+func TestMongoValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		v        interface{}
+		bsonType string
+		want     interface{}
+	}{
+		{"null", nil, "long", nil},
+		{"long", int64(42), "long", map[string]string{"$numberLong": "42"}},
+		{"long from bool", true, "long", map[string]string{"$numberLong": "1"}},
+		{"long beyond int64", parser.Number("18446744073709551615"), "long", map[string]string{"$numberLong": "18446744073709551615"}},
+		{"long from text", "-7", "long", map[string]string{"$numberLong": "-7"}},
+		{"bool", int64(1), "bool", true},
+		{"bool from text", "0", "bool", false},
+		{"decimal", parser.Number("12.50"), "decimal", map[string]string{"$numberDecimal": "12.50"}},
+		{"double", int64(3), "double", map[string]string{"$numberDouble": "3"}},
+		{"datetime", "2024-02-29 13:45:01", "date", map[string]string{"$date": "2024-02-29T13:45:01.000Z"}},
+		{"date", "2024-02-29", "date", map[string]string{"$date": "2024-02-29T00:00:00.000Z"}},
+		{"timestamp with zone", "2024-02-29 13:45:01+02", "date", map[string]string{"$date": "2024-02-29T11:45:01.000Z"}},
+		{"zero date", "0000-00-00 00:00:00", "date", nil},
+		{"not a date", "someday", "date", nil},
+		{"binData", []byte("hi"), "binData", map[string]interface{}{"$binary": map[string]string{"base64": "aGk=", "subType": "00"}}},
+		{"set", "a,b", "array", []string{"a", "b"}},
+		{"empty set", "", "array", []string{}},
+		{"string from number", parser.Number("1.5"), "string", "1.5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mongoValue(tt.v, tt.bsonType); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMongoTableSchema(t *testing.T) {
+	table := Table{TableName: "events", Fields: []Field{
+		{Name: "id", Type: "bigint", PrimaryKey: true},
+		{Name: "at", Type: "datetime"},
+		{Name: "note", Type: "varchar(20)", Nullable: true},
+	}}
+
+	schema := mongoTableSchema(table, nil)
+	if want := []string{"id", "at"}; !reflect.DeepEqual(schema.Required, want) {
+		t.Errorf("required: got %v, want %v", schema.Required, want)
+	}
+	want := map[string]interface{}{
+		"id":   "long",
+		"at":   []string{"date", "null"},
+		"note": []string{"string", "null"},
+	}
+	for name, bsonType := range want {
+		if got := schema.Properties[name].BSONType; !reflect.DeepEqual(got, bsonType) {
+			t.Errorf("%s: got bsonType %v, want %v", name, got, bsonType)
+		}
+	}
+	if got := schema.Properties["note"].MaxLength; got != 20 {
+		t.Errorf("note: got maxLength %d, want 20", got)
+	}
+}
+
+// embeddedShop is shopTables with orders embedded into their users.
+func embeddedShop() []Table {
+	tables := shopTables()
+	tables[1].Fields[1].ForeignKey = &ForeignKey{ReferencedTable: "users", ReferencedField: "id"}
+	return tables
+}
+
+func TestMongoGenerateSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		gen     *MongoGenerator
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "one collection per table",
+			gen:  &MongoGenerator{},
+			want: []string{
+				"db.createCollection(\"users\", {\n  \"validator\": {\n    \"$jsonSchema\": {\n      \"bsonType\": \"object\",\n      \"required\": [\n        \"id\",\n        \"email\",\n        \"status\",\n        \"created_at\"\n      ],",
+				"\"status\": {\n          \"bsonType\": \"string\",\n          \"enum\": [\n            \"active\",\n            \"banned\"\n          ]\n        }",
+				"\"total\": {\n          \"bsonType\": \"decimal\"\n        }",
+				"\"validationLevel\": \"moderate\"",
+				"\"key\": {\n      \"email\": 1\n    },\n    \"name\": \"idx_users_email\",\n    \"unique\": true",
+				"db.createCollection(\"orders\", {",
+				"\"key\": {\n      \"user_id\": 1,\n      \"total\": 1\n    },\n    \"name\": \"idx_placed\"\n  }",
+			},
+		},
+		{
+			name: "embedded children",
+			gen:  &MongoGenerator{EmbedChildren: true},
+			want: []string{
+				"\"orders\": {\n          \"bsonType\": \"array\",\n          \"items\": {\n            \"bsonType\": \"object\",",
+				"\"key\": {\n      \"orders.id\": 1\n    },\n    \"name\": \"pk_orders\"\n  }",
+				"\"key\": {\n      \"orders.user_id\": 1,\n      \"orders.total\": 1\n    },\n    \"name\": \"orders_idx_placed\"\n  }",
+			},
+			notWant: []string{"db.createCollection(\"orders\""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.gen.GenerateSchema(embeddedShop())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("missing %q in:\n%s", w, got)
+				}
+			}
+			for _, w := range tt.notWant {
+				if strings.Contains(got, w) {
+					t.Errorf("unexpected %q in:\n%s", w, got)
+				}
+			}
+		})
+	}
+}
+
+// shopRows is a RowSource replaying fixed batches.
+type shopRows []parser.Insert
+
+func (r shopRows) Each(ctx context.Context, fn func(parser.Insert) error) error {
+	for _, ins := range r {
+		if err := fn(ins); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestMongoWriteDocuments(t *testing.T) {
+	rows := shopRows{
+		{Table: "orders", Columns: []string{"id", "user_id", "total"}, Rows: [][]interface{}{
+			{int64(10), int64(1), parser.Number("9.90")},
+			{int64(11), int64(1), parser.Number("0.00")},
+		}},
+		{Table: "users", Columns: []string{"id", "email", "status", "created_at", "bio"}, Rows: [][]interface{}{
+			{int64(1), "ada@example.com", "active", "2024-02-29 13:45:01", nil},
+			{int64(2), "linus@example.com", "banned", "0000-00-00 00:00:00", "kernel"},
+		}},
+	}
+
+	dir := t.TempDir()
+	g := &MongoGenerator{EmbedChildren: true}
+	if err := g.WriteDocuments(context.Background(), dir, embeddedShop(), rows); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"id":{"$numberLong":"1"},"email":"ada@example.com","status":"active","created_at":{"$date":"2024-02-29T13:45:01.000Z"},"bio":null,` +
+		`"orders":[{"id":{"$numberLong":"10"},"user_id":{"$numberLong":"1"},"total":{"$numberDecimal":"9.90"}},` +
+		`{"id":{"$numberLong":"11"},"user_id":{"$numberLong":"1"},"total":{"$numberDecimal":"0.00"}}]}` + "\n" +
+		`{"id":{"$numberLong":"2"},"email":"linus@example.com","status":"banned","created_at":null,"bio":"kernel","orders":[]}` + "\n"
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "orders.json")); !os.IsNotExist(err) {
+		t.Errorf("orders.json written for an embedded table: %v", err)
+	}
+}
@@ -0,0 +1,349 @@
+package generator
+
+import (
+	"bigdataimporter/internal/parser"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ToMySQLType maps a source column type to MySQL/MariaDB. MySQL types are
+// kept as they are; PostgreSQL types coming from pg_dump input are
+// translated to their closest MySQL equivalent.
+func ToMySQLType(srcType string) string {
+	t := strings.ToLower(strings.TrimSpace(srcType))
+	args := typeArgs(t)
+
+	switch {
+	case strings.HasSuffix(t, "[]"):
+		return "JSON"
+	case strings.HasPrefix(t, "character varying"), strings.HasPrefix(t, "varchar"):
+		if args == "" {
+			return "LONGTEXT"
+		}
+		return "VARCHAR" + args
+	case strings.HasPrefix(t, "character"), strings.HasPrefix(t, "char"):
+		return "CHAR" + args
+	case strings.HasPrefix(t, "timestamp"):
+		if strings.HasPrefix(t, "timestamp with") || strings.HasPrefix(t, "timestamptz") {
+			return "DATETIME"
+		}
+	case strings.HasPrefix(t, "time "), strings.HasPrefix(t, "timetz"):
+		return "TIME"
+	case strings.HasPrefix(t, "double precision"):
+		return "DOUBLE"
+	}
+
+	if isSpatialType(mysqlBaseType(t)) {
+		return strings.ToUpper(mysqlBaseType(t))
+	}
+	if base := mysqlBaseType(t); base == "enum" || base == "set" {
+		// Members are case- and space-sensitive: keep them as written.
+		ct := parser.ParseColumnType(srcType)
+		ct.Base = strings.ToUpper(ct.Base)
+		return ct.String()
+	}
+
+	switch mysqlBaseType(t) {
+	case "tinyint", "smallint", "mediumint", "int", "bigint", "decimal", "float", "double",
+		"tinytext", "text", "mediumtext", "longtext", "tinyblob", "blob", "mediumblob", "longblob",
+		"binary", "varbinary", "date", "datetime", "timestamp", "time", "year", "json", "bit":
+		return strings.ToUpper(mysqlBaseType(t)) + args + typeModifiers(t)
+	case "integer", "int4", "serial", "serial4":
+		return "INT"
+	case "int8", "bigserial", "serial8":
+		return "BIGINT"
+	case "int2", "smallserial", "serial2":
+		return "SMALLINT"
+	case "boolean", "bool":
+		return "TINYINT(1)"
+	case "numeric", "dec", "fixed":
+		if args == "" {
+			return "DECIMAL(65,30)"
+		}
+		return "DECIMAL" + args
+	case "real", "float4":
+		return "FLOAT"
+	case "float8":
+		return "DOUBLE"
+	case "bytea":
+		return "LONGBLOB"
+	case "jsonb":
+		return "JSON"
+	case "uuid":
+		return "CHAR(36)"
+	case "inet", "cidr", "macaddr":
+		return "VARCHAR(43)"
+	case "interval":
+		return "VARCHAR(64)"
+	}
+	return "LONGTEXT"
+}
+
+// typeArgs returns the "(...)" part of a type, or "" when it is missing or
+// was cut off.
+func typeArgs(t string) string {
+	start := strings.Index(t, "(")
+	if start < 0 {
+		return ""
+	}
+	end := strings.Index(t[start:], ")")
+	if end < 0 {
+		return ""
+	}
+	return strings.ReplaceAll(t[start:start+end+1], " ", "")
+}
+
+func typeModifiers(t string) string {
+	var mods string
+	if strings.Contains(t, "unsigned") {
+		mods += " UNSIGNED"
+	}
+	if strings.Contains(t, "zerofill") {
+		mods += " ZEROFILL"
+	}
+	return mods
+}
+
+func QuoteMySQLIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func mysqlIdentList(names []string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = QuoteMySQLIdent(n)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// mysqlEngine moves tables to InnoDB unless they use an engine with a
+// purpose of its own; MyISAM and Aria cannot enforce foreign keys.
+func mysqlEngine(engine string) string {
+	switch strings.ToLower(engine) {
+	case "", "myisam", "aria", "innodb":
+		return "InnoDB"
+	default:
+		return engine
+	}
+}
+
+func mysqlCharset(charset string) string {
+	switch strings.ToLower(charset) {
+	case "", "utf8", "utf8mb3", "utf8mb4":
+		return "utf8mb4"
+	default:
+		return charset
+	}
+}
+
+// mysqlColumnCharset keeps the character set and collation of a text column,
+// moved from utf8 to utf8mb4 like the tables.
+func mysqlColumnCharset(ct parser.ColumnType, myType string) string {
+	switch strings.ToLower(mysqlBaseType(myType)) {
+	case "char", "varchar", "tinytext", "text", "mediumtext", "longtext", "enum", "set":
+	default:
+		return ""
+	}
+	var s string
+	if ct.Charset != "" {
+		s += " CHARACTER SET " + mysqlCharset(ct.Charset)
+	}
+	if ct.Collation != "" {
+		c := ct.Collation
+		for _, prefix := range []string{"utf8mb3_", "utf8_"} {
+			if strings.HasPrefix(strings.ToLower(c), prefix) {
+				c = "utf8mb4_" + c[len(prefix):]
+				break
+			}
+		}
+		s += " COLLATE " + c
+	}
+	return s
+}
+
+// mysqlIndex spells a key of a CREATE TABLE, with its prefix lengths.
+// MySQL only indexes TEXT and BLOB columns by a prefix, so keys on them from
+// other sources get one.
+func mysqlIndex(table string, ix parser.Index, myTypes map[string]string) string {
+	kind := "KEY"
+	switch {
+	case ix.Type == parser.IndexFulltext:
+		kind = "FULLTEXT KEY"
+	case ix.Type == parser.IndexSpatial:
+		kind = "SPATIAL KEY"
+	case ix.Unique:
+		kind = "UNIQUE KEY"
+	}
+	cols := make([]string, len(ix.Columns))
+	for i, c := range ix.Columns {
+		cols[i] = QuoteMySQLIdent(c.Name)
+		length := c.Length
+		if t := myTypes[c.Name]; length == 0 && ix.Type == "" && (strings.HasSuffix(t, "TEXT") || strings.HasSuffix(t, "BLOB")) {
+			length = 255
+		}
+		if length > 0 {
+			cols[i] += fmt.Sprintf("(%d)", length)
+		}
+	}
+	return fmt.Sprintf("%s %s (%s)", kind, QuoteMySQLIdent(indexName(table, ix)), strings.Join(cols, ","))
+}
+
+func GenerateMySQLSchema(tables []Table) (string, error) {
+	return generateMySQLSchema(tables, nil)
+}
+
+func generateMySQLSchema(tables []Table, types *TypeMap) (string, error) {
+	var sb strings.Builder
+	var allAlters []string
+	// Foreign key names are unique per database in MySQL.
+	fkNames := map[string]bool{}
+
+	sb.WriteString("SET FOREIGN_KEY_CHECKS=0;\n\n")
+
+	for _, table := range tables {
+		if table.TableName == "" {
+			continue
+		}
+
+		var defs []string
+		myTypes := map[string]string{}
+		for _, f := range table.Fields {
+			myType := types.Column(DialectMySQL, table.TableName, f.Name, f.ColumnType(), f.AutoIncrement)
+			myTypes[f.Name] = strings.ToUpper(mysqlBaseType(myType))
+			col := fmt.Sprintf("  %s %s", QuoteMySQLIdent(f.Name), myType)
+
+			col += mysqlColumnCharset(f.ColumnType(), myType)
+			if !f.Nullable {
+				col += " NOT NULL"
+			}
+			if def := mysqlDefault(f.Default, myType); def != "" {
+				col += " DEFAULT " + def
+			}
+			if f.AutoIncrement {
+				col += " AUTO_INCREMENT"
+			}
+			defs = append(defs, col)
+		}
+
+		for _, fk := range tableForeignKeys(table) {
+			// InnoDB rejects SET DEFAULT and cannot defer checks.
+			if fk.OnDelete == "SET DEFAULT" {
+				fk.OnDelete = ""
+			}
+			if fk.OnUpdate == "SET DEFAULT" {
+				fk.OnUpdate = ""
+			}
+			allAlters = append(allAlters,
+				fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)%s;",
+					QuoteMySQLIdent(table.TableName), QuoteMySQLIdent(schemaName(fkNames, table.TableName, foreignKeyName(table.TableName, fk))),
+					mysqlIdentList(fk.Columns), QuoteMySQLIdent(fk.ReferencedTable), mysqlIdentList(fk.ReferencedColumns),
+					foreignKeyActions(fk, false)))
+		}
+
+		primaryKey := table.PrimaryKey
+		if len(primaryKey) == 0 {
+			for _, f := range table.Fields {
+				if f.PrimaryKey {
+					primaryKey = append(primaryKey, f.Name)
+				}
+			}
+		}
+		if len(primaryKey) > 0 {
+			quoted := make([]string, len(primaryKey))
+			for i, pk := range primaryKey {
+				quoted[i] = QuoteMySQLIdent(pk)
+			}
+			defs = append(defs, fmt.Sprintf("  PRIMARY KEY (%s)", strings.Join(quoted, ", ")))
+		}
+		for _, ix := range tableIndexes(table) {
+			defs = append(defs, "  "+mysqlIndex(table.TableName, ix, myTypes))
+		}
+
+		sb.WriteString(fmt.Sprintf("CREATE TABLE %s (\n", QuoteMySQLIdent(table.TableName)))
+		sb.WriteString(strings.Join(defs, ",\n"))
+		sb.WriteString(fmt.Sprintf("\n) ENGINE=%s DEFAULT CHARSET=%s;\n\n", mysqlEngine(table.Engine), mysqlCharset(table.Charset)))
+	}
+
+	if len(allAlters) > 0 {
+		sb.WriteString("-- Foreign Keys\n")
+		for _, a := range allAlters {
+			sb.WriteString(a + "\n")
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("SET FOREIGN_KEY_CHECKS=1;\n")
+	return sb.String(), nil
+}
+
+func mysqlDefault(defRaw, myType string) string {
+	defRaw = strings.TrimSpace(defRaw)
+	def := strings.ToLower(defRaw)
+	reNumericType := regexp.MustCompile(`^(TINYINT|SMALLINT|MEDIUMINT|INT|BIGINT|DECIMAL|FLOAT|DOUBLE)`)
+
+	switch {
+	case defRaw == "":
+		return ""
+	case def == "current_timestamp()" || def == "current_timestamp" || def == "now()":
+		return "CURRENT_TIMESTAMP"
+	case def == "null":
+		return "NULL"
+	case isBitLiteral(def):
+		return def
+	case strings.HasSuffix(myType, "TEXT") || strings.HasSuffix(myType, "BLOB") || myType == "JSON":
+		// MySQL rejects literal defaults on these types.
+		return ""
+	case reNumericType.MatchString(myType):
+		if _, err := strconv.ParseFloat(strings.Trim(defRaw, "'"), 64); err == nil {
+			return strings.Trim(defRaw, "'")
+		}
+		if def == "true" || def == "false" {
+			return strings.ToUpper(def)
+		}
+		fallthrough
+	default:
+		v := strings.Trim(defRaw, "'")
+		v = strings.ReplaceAll(v, `\`, `\\`)
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	}
+}
+
+// isBitLiteral reports a MySQL b'…' or x'…' literal as the parser keeps it.
+func isBitLiteral(def string) bool {
+	return len(def) >= 3 && (def[0] == 'b' || def[0] == 'x') && def[1] == '\'' && def[len(def)-1] == '\''
+}
+
+// bitLiteral returns the value of a b'…' or x'…' literal that fits in 64
+// bits.
+func bitLiteral(def string) (uint64, bool) {
+	if !isBitLiteral(def) {
+		return 0, false
+	}
+	digits, base := def[2:len(def)-1], 2
+	if def[0] == 'x' {
+		base = 16
+	}
+	if digits == "" {
+		return 0, true
+	}
+	n, err := strconv.ParseUint(digits, base, 64)
+	return n, err == nil
+}
+
+// MySQLGenerator emits MySQL/MariaDB DDL. It normalizes dumps between MySQL
+// flavours (InnoDB, utf8mb4) and translates pg_dump input. Only tables are
+// emitted: views, triggers and routines of the dump are skipped, and the
+// parser logs each one.
+type MySQLGenerator struct {
+	Types *TypeMap // type overrides; built-in mapping when nil
+}
+
+func (m *MySQLGenerator) GenerateSchema(tables []Table) (string, error) {
+	return generateMySQLSchema(tables, m.Types)
+}
+
+func (m *MySQLGenerator) ImportData(tables []Table) error {
+	return nil
+}
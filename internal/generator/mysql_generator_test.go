@@ -0,0 +1,63 @@
+package generator
+
+import "testing"
+
+func TestGenerateMySQLSchema(t *testing.T) {
+	want := "SET FOREIGN_KEY_CHECKS=0;\n\n" +
+		"CREATE TABLE `users` (\n" +
+		"  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,\n" +
+		"  `email` VARCHAR(255) NOT NULL,\n" +
+		"  `status` ENUM('active','banned') NOT NULL DEFAULT 'active',\n" +
+		"  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,\n" +
+		"  `bio` TEXT,\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  UNIQUE KEY `idx_users_email` (`email`)\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;\n\n" +
+		"CREATE TABLE `orders` (\n" +
+		"  `id` INT NOT NULL,\n" +
+		"  `user_id` BIGINT UNSIGNED NOT NULL,\n" +
+		"  `total` DECIMAL(10,2) NOT NULL DEFAULT 0.00,\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  KEY `idx_placed` (`user_id`,`total`)\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;\n\n" +
+		"-- Foreign Keys\n" +
+		"ALTER TABLE `orders` ADD CONSTRAINT `fk_orders_user_id` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE;\n\n" +
+		"SET FOREIGN_KEY_CHECKS=1;\n"
+
+	got, err := GenerateMySQLSchema(shopTables())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestToMySQLType(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"int(11) unsigned zerofill", "INT(11) UNSIGNED ZEROFILL"},
+		{"varchar(40)", "VARCHAR(40)"},
+		{"enum('A b','c')", "ENUM('A b','c')"},
+		{"character varying(20)", "VARCHAR(20)"},
+		{"character varying", "LONGTEXT"},
+		{"timestamp with time zone", "DATETIME"},
+		{"timestamp(3)", "TIMESTAMP(3)"},
+		{"double precision", "DOUBLE"},
+		{"integer", "INT"},
+		{"bigserial", "BIGINT"},
+		{"boolean", "TINYINT(1)"},
+		{"numeric", "DECIMAL(65,30)"},
+		{"numeric(12, 4)", "DECIMAL(12,4)"},
+		{"bytea", "LONGBLOB"},
+		{"jsonb", "JSON"},
+		{"text[]", "JSON"},
+		{"uuid", "CHAR(36)"},
+		{"point", "POINT"},
+		{"tsvector", "LONGTEXT"},
+	}
+	for _, tt := range tests {
+		if got := ToMySQLType(tt.in); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
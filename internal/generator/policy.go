@@ -0,0 +1,27 @@
+package generator
+
+import "strings"
+
+// Policies for target tables that already exist, chosen per job.
+const (
+	IfExistsFail     = "fail"     // stop before touching anything
+	IfExistsSkip     = "skip"     // keep the table and its rows, load nothing into it
+	IfExistsReplace  = "replace"  // drop and recreate the table
+	IfExistsTruncate = "truncate" // keep the table, delete its rows first
+	IfExistsAppend   = "append"   // keep the table and its rows, add the new ones
+)
+
+// ValidIfExists reports whether p names a policy; empty means the default,
+// fail.
+func ValidIfExists(p string) bool {
+	switch p {
+	case "", IfExistsFail, IfExistsSkip, IfExistsReplace, IfExistsTruncate, IfExistsAppend:
+		return true
+	}
+	return false
+}
+
+// IfExistsPolicies lists the policies for usage and error messages.
+func IfExistsPolicies() string {
+	return strings.Join([]string{IfExistsFail, IfExistsSkip, IfExistsReplace, IfExistsTruncate, IfExistsAppend}, "|")
+}
@@ -1,8 +1,8 @@
 package generator
 
 import (
+	"bigdataimporter/internal/parser"
 	"fmt"
-	"regexp"
 	"strings"
 )
 
@@ -11,10 +11,23 @@ type Field struct {
 	Type          string      `json:"type"`
 	Nullable      bool        `json:"nullable"`
 	PrimaryKey    bool        `json:"primary_key"`
+	Unique        bool        `json:"unique"`
 	AutoIncrement bool        `json:"auto_increment"`
 	Default       string      `json:"default"`
 	Index         bool        `json:"index"`
 	ForeignKey    *ForeignKey `json:"foreign_key"`
+
+	// TypeInfo is Type taken apart; see ColumnType.
+	TypeInfo parser.ColumnType `json:"type_info"`
+}
+
+// ColumnType returns the structured type of the field, reading it from Type
+// when TypeInfo is not set.
+func (f Field) ColumnType() parser.ColumnType {
+	if f.TypeInfo.Base != "" {
+		return f.TypeInfo
+	}
+	return parser.ParseColumnType(f.Type)
 }
 
 type ForeignKey struct {
@@ -28,52 +41,175 @@ type Table struct {
 	Engine     string   `json:"engine,omitempty"`
 	Charset    string   `json:"charset,omitempty"`
 	PrimaryKey []string `json:"primary_keys,omitempty"`
+
+	// Indexes are the keys of the table; fields flagged Index or Unique
+	// that no key covers get a single-column one, see tableIndexes.
+	Indexes []parser.Index `json:"indexes,omitempty"`
+
+	// ForeignKeys are the foreign keys of the table; fields with a
+	// ForeignKey no key covers add a single-column one, see tableForeignKeys.
+	ForeignKeys []parser.ForeignKey `json:"foreign_keys,omitempty"`
 }
 
+// MySQLToPostgreType is the built-in mapping of a MySQL column type to
+// PostgreSQL; config.yaml can override it through a TypeMap.
 func MySQLToPostgreType(mysqlType string, autoIncrement bool) string {
-	t := strings.ToLower(mysqlType)
-	switch {
-	case autoIncrement:
+	return postgresType(parser.ParseColumnType(mysqlType), autoIncrement)
+}
+
+func postgresType(ct parser.ColumnType, autoIncrement bool) string {
+	if autoIncrement {
+		if ct.Base == "bigint" || (ct.Base == "int" && ct.Unsigned) {
+			return "BIGSERIAL"
+		}
 		return "SERIAL"
-	case strings.Contains(t, "tinyint"):
+	}
+
+	switch ct.Base {
+	case "bool", "boolean":
+		return "BOOLEAN"
+	case "tinyint":
+		if ct.Length == 1 && !ct.Unsigned {
+			return "BOOLEAN"
+		}
 		return "SMALLINT"
-	case strings.Contains(t, "bigint"):
-		return "BIGINT"
-	case strings.Contains(t, "int"):
+	case "smallint":
+		if ct.Unsigned {
+			return "INTEGER"
+		}
+		return "SMALLINT"
+	case "mediumint":
+		return "INTEGER"
+	case "int", "integer":
+		if ct.Unsigned {
+			return "BIGINT"
+		}
 		return "INTEGER"
-	case strings.Contains(t, "varchar"):
-		return t
-	case strings.Contains(t, "text"):
+	case "bigint":
+		if ct.Unsigned {
+			return "NUMERIC(20)"
+		}
+		return "BIGINT"
+	case "bit":
+		if ct.Length <= 1 {
+			return "BOOLEAN"
+		}
+		return "BIGINT"
+	case "varchar":
+		if ct.Length > 0 {
+			return fmt.Sprintf("VARCHAR(%d)", ct.Length)
+		}
 		return "TEXT"
-	case strings.Contains(t, "datetime"), strings.Contains(t, "timestamp"):
+	case "char":
+		if ct.Length > 0 {
+			return fmt.Sprintf("CHAR(%d)", ct.Length)
+		}
+		return "CHAR"
+	case "tinytext", "text", "mediumtext", "longtext":
+		return "TEXT"
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob":
+		return "BYTEA"
+	case "datetime", "timestamp":
+		if ct.Precision > 0 {
+			return fmt.Sprintf("TIMESTAMP(%d)", ct.Precision)
+		}
 		return "TIMESTAMP"
-	case strings.Contains(t, "date"):
+	case "date":
 		return "DATE"
-	case strings.Contains(t, "decimal"):
+	case "time":
+		if ct.Precision > 0 {
+			return fmt.Sprintf("TIME(%d)", ct.Precision)
+		}
+		return "TIME"
+	case "year":
+		return "SMALLINT"
+	case "decimal", "numeric", "dec", "fixed":
+		switch {
+		case ct.Precision > 0 && ct.Scale > 0:
+			return fmt.Sprintf("NUMERIC(%d,%d)", ct.Precision, ct.Scale)
+		case ct.Precision > 0:
+			return fmt.Sprintf("NUMERIC(%d)", ct.Precision)
+		}
 		return "NUMERIC"
-	case strings.Contains(t, "float"), strings.Contains(t, "double"):
+	case "float":
+		// FLOAT(p) is a DOUBLE in MySQL from 25 digits on.
+		if ct.Precision > 24 {
+			return "DOUBLE PRECISION"
+		}
+		return "REAL"
+	case "double", "real":
 		return "DOUBLE PRECISION"
-	default:
+	case "json":
+		return "JSONB"
+	case "enum":
 		return "TEXT"
+	case "set":
+		return "TEXT[]"
+	}
+	if isSpatialType(ct.Base) {
+		// MySQL dumps spatial values as internal WKB, which is kept as is.
+		return "BYTEA"
 	}
+	return "TEXT"
 }
 
 func GeneratePostgreSQLSchema(tables []Table) (string, error) {
+	return generatePostgreSQLSchema(tables, IfExistsFail, nil)
+}
+
+// generatePostgreSQLSchema writes the schema for the given if_exists policy.
+// Under fail a plain CREATE TABLE stops at the first existing table; the
+// other policies create missing tables only, replace drops the existing ones
+// first and truncate empties them.
+func generatePostgreSQLSchema(tables []Table, ifExists string, types *TypeMap) (string, error) {
 	var sb strings.Builder
+	var enumTypes []string
+	var dropTypes []string
 	var allAlters []string
 	var allIndexes []string
+	var names []string
+	usedNames := map[string]bool{}
+
+	for _, table := range tables {
+		if table.TableName != "" {
+			names = append(names, table.TableName)
+			usedNames[strings.ToLower(table.TableName)] = true
+		}
+	}
+
+	create := "CREATE TABLE IF NOT EXISTS"
+	switch ifExists {
+	case "", IfExistsFail, IfExistsReplace:
+		create = "CREATE TABLE"
+	}
+	// Tables that are kept may already have their foreign keys.
+	keepConstraints := create != "CREATE TABLE"
 
 	for _, table := range tables {
 		if table.TableName == "" {
 			continue
 		}
 
-		sb.WriteString(fmt.Sprintf("CREATE TABLE %s (\n", table.TableName))
+		sb.WriteString(fmt.Sprintf("%s %s (\n", create, table.TableName))
 
-		for i, f := range table.Fields {
-			pgType := MySQLToPostgreType(f.Type, f.AutoIncrement)
+		var defs []string
+		pgTypes := map[string]string{}
+		for _, f := range table.Fields {
+			ct := f.ColumnType()
+			pgType, builtin := types.lookup(DialectPostgres, table.TableName, f.Name, ct, f.AutoIncrement)
+			upper := strings.ToUpper(pgType)
+			pgTypes[f.Name] = upper
 			col := fmt.Sprintf("  %s %s", f.Name, pgType)
 
+			if builtin && ct.Base == "enum" && pgType == postgresEnumName(table.TableName, f.Name) {
+				enumType := fmt.Sprintf("CREATE TYPE %s AS ENUM (%s);", pgType, postgresQuoteList(ct.Values))
+				if keepConstraints {
+					enumType = fmt.Sprintf("DO $$ BEGIN\n  %s\nEXCEPTION WHEN duplicate_object THEN NULL;\nEND $$;", enumType)
+				}
+				enumTypes = append(enumTypes, enumType)
+				dropTypes = append(dropTypes, pgType)
+			}
+
 			if !f.Nullable {
 				col += " NOT NULL"
 			}
@@ -87,47 +223,95 @@ func GeneratePostgreSQLSchema(tables []Table) (string, error) {
 					col += " DEFAULT current_timestamp"
 				case def == "null" || def == "NULL":
 					col += " DEFAULT NULL"
-				case strings.HasPrefix(pgType, "DATE") || strings.HasPrefix(pgType, "TIMESTAMP"):
+				case upper == "BOOLEAN":
+					// Anything else has no certain truth value; no default is
+					// safer than an inverted one.
+					switch def {
+					case "1", "'1'", "true", "b'1'":
+						col += " DEFAULT true"
+					case "0", "'0'", "false", "b'0'":
+						col += " DEFAULT false"
+					}
+				case isBitLiteral(def):
+					if n, ok := bitLiteral(def); ok && (strings.HasPrefix(upper, "INT") || strings.HasPrefix(upper, "SMALLINT") ||
+						strings.HasPrefix(upper, "BIGINT") || strings.HasPrefix(upper, "NUMERIC")) {
+						col += fmt.Sprintf(" DEFAULT %d", n)
+					} else if upper == "BYTEA" && def[0] == 'x' {
+						col += fmt.Sprintf(" DEFAULT '\\x%s'", def[2:len(def)-1])
+					}
+				case upper == "TEXT[]":
+					col += fmt.Sprintf(" DEFAULT '%s'", strings.ReplaceAll(PostgresArrayLiteral(strings.Trim(defRaw, "'")), "'", "''"))
+				case strings.HasPrefix(upper, "DATE") || strings.HasPrefix(upper, "TIMESTAMP"):
 					if defRaw != "0000-00-00" && defRaw != "'0000-00-00'" && defRaw != "''" {
 						col += fmt.Sprintf(" DEFAULT '%s'", strings.Trim(defRaw, "'"))
 					}
-				case strings.HasPrefix(pgType, "INT") || strings.HasPrefix(pgType, "NUMERIC") ||
-					strings.HasPrefix(pgType, "SMALLINT") || strings.HasPrefix(pgType, "BIGINT") ||
-					strings.HasPrefix(pgType, "DOUBLE"):
+				case strings.HasPrefix(upper, "INT") || strings.HasPrefix(upper, "NUMERIC") ||
+					strings.HasPrefix(upper, "SMALLINT") || strings.HasPrefix(upper, "BIGINT") ||
+					strings.HasPrefix(upper, "DOUBLE") || upper == "REAL":
 					col += fmt.Sprintf(" DEFAULT %s", strings.Trim(defRaw, "'"))
 				case def == "true" || def == "false" || def == "1" || def == "0":
 					col += fmt.Sprintf(" DEFAULT %s", def)
 				default:
-					col += fmt.Sprintf(" DEFAULT '%s'", strings.Trim(defRaw, "'"))
+					col += fmt.Sprintf(" DEFAULT '%s'", strings.ReplaceAll(strings.Trim(defRaw, "'"), "'", "''"))
 				}
 			}
 
-			if f.PrimaryKey {
+			if f.PrimaryKey && len(table.PrimaryKey) <= 1 {
 				col += " PRIMARY KEY"
 			}
 
-			if i < len(table.Fields)-1 {
-				col += ","
+			if builtin && len(ct.Values) > 0 {
+				check := fmt.Sprintf("chk_%s_%s", table.TableName, f.Name)
+				switch {
+				case ct.Base == "set":
+					col += fmt.Sprintf(" CONSTRAINT %s CHECK (%s <@ ARRAY[%s]::TEXT[])", check, f.Name, postgresQuoteList(ct.Values))
+				case ct.Base == "enum" && upper == "TEXT":
+					col += fmt.Sprintf(" CONSTRAINT %s CHECK (%s IN (%s))", check, f.Name, postgresQuoteList(ct.Values))
+				}
 			}
-			sb.WriteString(col + "\n")
 
-			if f.ForeignKey != nil && f.ForeignKey.ReferencedTable != "" && f.ForeignKey.ReferencedField != "" {
-				fkName := fmt.Sprintf("fk_%s_%s", table.TableName, f.Name)
-				allAlters = append(allAlters,
-					fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s(%s);",
-						table.TableName, fkName, f.Name, f.ForeignKey.ReferencedTable, f.ForeignKey.ReferencedField))
-			}
-			if f.Index {
-				allIndexes = append(allIndexes,
-					fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_%s ON %s(%s);",
-						table.TableName, f.Name, table.TableName, f.Name))
+			defs = append(defs, col)
+
+		}
+
+		for _, fk := range tableForeignKeys(table) {
+			alter := fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s(%s)%s;",
+				table.TableName, foreignKeyName(table.TableName, fk), strings.Join(fk.Columns, ", "),
+				fk.ReferencedTable, strings.Join(fk.ReferencedColumns, ", "), foreignKeyActions(fk, true))
+			if keepConstraints {
+				alter = fmt.Sprintf("DO $$ BEGIN\n  %s\nEXCEPTION WHEN duplicate_object THEN NULL;\nEND $$;", alter)
 			}
+			allAlters = append(allAlters, alter)
 		}
 
 		if len(table.PrimaryKey) > 1 {
-			sb.WriteString(fmt.Sprintf(",  PRIMARY KEY (%s)\n", strings.Join(table.PrimaryKey, ", ")))
+			defs = append(defs, fmt.Sprintf("  PRIMARY KEY (%s)", strings.Join(table.PrimaryKey, ", ")))
 		}
-		sb.WriteString(");\n\n")
+		for _, ix := range tableIndexes(table) {
+			name := schemaIndexName(usedNames, table.TableName, ix)
+			switch {
+			case ix.Type == parser.IndexFulltext:
+				allIndexes = append(allIndexes, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s USING GIN (to_tsvector('simple', %s));",
+					name, table.TableName, postgresFulltextDocument(ix)))
+			case ix.Type == parser.IndexSpatial:
+				allIndexes = append(allIndexes, postgresSpatialIndex(name, table.TableName, ix, pgTypes))
+			case ix.Unique:
+				// Prefix lengths are dropped: a key on the whole value
+				// accepts every row the prefix key did.
+				defs = append(defs, fmt.Sprintf("  CONSTRAINT %s UNIQUE (%s)", name, strings.Join(ix.ColumnNames(), ", ")))
+			default:
+				allIndexes = append(allIndexes, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s);",
+					name, table.TableName, postgresIndexColumns(ix, pgTypes)))
+			}
+		}
+
+		sb.WriteString(strings.Join(defs, ",\n"))
+		sb.WriteString("\n);\n\n")
+	}
+
+	if ifExists == IfExistsTruncate && len(names) > 0 {
+		sb.WriteString("-- Empty existing tables\n")
+		sb.WriteString(fmt.Sprintf("TRUNCATE TABLE %s RESTART IDENTITY;\n\n", strings.Join(names, ", ")))
 	}
 
 	if len(allAlters) > 0 {
@@ -146,64 +330,112 @@ func GeneratePostgreSQLSchema(tables []Table) (string, error) {
 		sb.WriteString("\n")
 	}
 
-	return sb.String(), nil
+	// Drops and enum types go before the tables that use them.
+	var pre strings.Builder
+	if ifExists == IfExistsReplace {
+		// CASCADE also drops foreign keys other tables hold on these.
+		pre.WriteString("-- Replace existing tables\n")
+		for _, name := range names {
+			pre.WriteString(fmt.Sprintf("DROP TABLE IF EXISTS %s CASCADE;\n", name))
+		}
+		for _, name := range dropTypes {
+			pre.WriteString(fmt.Sprintf("DROP TYPE IF EXISTS %s CASCADE;\n", name))
+		}
+		pre.WriteString("\n")
+	}
+	if len(enumTypes) > 0 {
+		pre.WriteString("-- Enum types\n")
+		for _, t := range enumTypes {
+			pre.WriteString(t + "\n")
+		}
+		pre.WriteString("\n")
+	}
+
+	return pre.String() + sb.String(), nil
+}
+
+// postgresIndexColumns lists the key of a B-tree index. A MySQL prefix key
+// on a TEXT or BYTEA column becomes an expression on the prefix, which also
+// keeps long values under the size limit of index entries.
+func postgresIndexColumns(ix parser.Index, pgTypes map[string]string) string {
+	cols := make([]string, len(ix.Columns))
+	for i, c := range ix.Columns {
+		switch {
+		case c.Length > 0 && pgTypes[c.Name] == "TEXT":
+			cols[i] = fmt.Sprintf("left(%s, %d)", c.Name, c.Length)
+		case c.Length > 0 && pgTypes[c.Name] == "BYTEA":
+			cols[i] = fmt.Sprintf("substring(%s, 1, %d)", c.Name, c.Length)
+		default:
+			cols[i] = c.Name
+		}
+	}
+	return strings.Join(cols, ", ")
+}
+
+// postgresFulltextDocument joins the columns of a FULLTEXT key into the text
+// that is indexed; the simple configuration matches MySQL in not stemming.
+func postgresFulltextDocument(ix parser.Index) string {
+	parts := make([]string, len(ix.Columns))
+	for i, c := range ix.Columns {
+		parts[i] = fmt.Sprintf("coalesce(%s, '')", c.Name)
+	}
+	return strings.Join(parts, " || ' ' || ")
+}
+
+// postgresSpatialIndex makes a GiST index for a SPATIAL key. GiST needs
+// PostGIS types, so columns left as BYTEA by the built-in mapping get a note
+// instead; type_mappings can map them to geometry.
+func postgresSpatialIndex(name, table string, ix parser.Index, pgTypes map[string]string) string {
+	for _, c := range ix.Columns {
+		t := pgTypes[c.Name]
+		if !strings.HasPrefix(t, "GEOMETRY") && !strings.HasPrefix(t, "GEOGRAPHY") {
+			return fmt.Sprintf("-- SPATIAL key %s on %s(%s) skipped: %s is %s, map it to geometry in type_mappings",
+				name, table, strings.Join(ix.ColumnNames(), ", "), c.Name, t)
+		}
+	}
+	return fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s USING GIST (%s);", name, table, strings.Join(ix.ColumnNames(), ", "))
+}
+
+// postgresEnumName names the type created for an ENUM column.
+func postgresEnumName(table, column string) string {
+	return fmt.Sprintf("%s_%s_enum", table, column)
+}
+
+func postgresQuoteList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// PostgresArrayLiteral turns the comma-separated value of a SET column into
+// a text[] literal.
+func PostgresArrayLiteral(set string) string {
+	if set == "" {
+		return "{}"
+	}
+	members := strings.Split(set, ",")
+	for i, m := range members {
+		m = strings.ReplaceAll(m, `\`, `\\`)
+		members[i] = `"` + strings.ReplaceAll(m, `"`, `\"`) + `"`
+	}
+	return "{" + strings.Join(members, ",") + "}"
 }
 
 func GeneratePostgreSQL(table Table) (string, error) {
 	return GeneratePostgreSQLSchema([]Table{table})
 }
 
-type PostgreGenerator struct{}
+type PostgreGenerator struct {
+	IfExists string   // policy for tables that already exist, see IfExistsFail
+	Types    *TypeMap // type overrides; built-in mapping when nil
+}
 
 func (p *PostgreGenerator) GenerateSchema(tables []Table) (string, error) {
-	return GeneratePostgreSQLSchema(tables)
+	return generatePostgreSQLSchema(tables, p.IfExists, p.Types)
 }
 
 func (p *PostgreGenerator) ImportData(tables []Table) error {
 	return nil
 }
-
-func NormalizePostgresSyntax(sql string) string {
-	sql = strings.ReplaceAll(sql, "`", "\"")
-	sql = strings.ReplaceAll(sql, "\\'", "''")
-	sql = strings.ReplaceAll(sql, "’", "''")
-	sql = strings.ReplaceAll(sql, "‘", "''")
-	sql = strings.ReplaceAll(sql, "´", "''")
-	sql = strings.ReplaceAll(sql, "\\\\", "\\")
-	sql = strings.ReplaceAll(sql, "TRUE", "true")
-	sql = strings.ReplaceAll(sql, "FALSE", "false")
-	sql = strings.ReplaceAll(sql, "ENGINE=InnoDB", "")
-	sql = strings.ReplaceAll(sql, "CHARSET=utf8mb4", "")
-	sql = strings.ReplaceAll(sql, "\r", " ")
-	sql = strings.ReplaceAll(sql, "\n", " ")
-	sql = strings.ReplaceAll(sql, "'0000-00-00'", "'1970-01-01'")
-	sql = strings.ReplaceAll(sql, "'0000-00-00 00:00:00'", "'1970-01-01 00:00:00'")
-	sql = strings.ReplaceAll(sql, " DEFAULT NULL", "")
-	sql = strings.ReplaceAll(sql, "b'0'", "false")
-	sql = strings.ReplaceAll(sql, "b'1'", "true")
-	sql = strings.ReplaceAll(sql, " ,", ",")
-	sql = strings.ReplaceAll(sql, ", ", ", ")
-	return strings.TrimSpace(sql)
-}
-
-func SafeNormalize(sql string) string {
-	re := regexp.MustCompile(`VALUES\s*\(([^)]+)\)`)
-	return re.ReplaceAllStringFunc(sql, func(match string) string {
-		values := match[len("VALUES (") : len(match)-1]
-		parts := strings.Split(values, ",")
-		for i, p := range parts {
-			p = strings.TrimSpace(p)
-			// Boş veya NULL geç
-			if strings.EqualFold(p, "NULL") || p == "" {
-				continue
-			}
-			// Eğer tırnak yoksa ve harf içermiyorsa tırnakla
-			if !strings.Contains(p, "'") && regexp.MustCompile(`[A-Za-z]`).MatchString(p) {
-				// içeri harf varsa olduğu gibi bırak
-			} else if !strings.Contains(p, "'") {
-				parts[i] = "'" + p + "'"
-			}
-		}
-		return "VALUES (" + strings.Join(parts, ", ") + ")"
-	})
-}
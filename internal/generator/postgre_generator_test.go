@@ -0,0 +1,110 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"bigdataimporter/internal/parser"
+)
+
+// shopTables is a small schema covering what every target has to translate:
+// an auto-increment key, a unique column, an enum with a default, a
+// timestamp default, a decimal, a foreign key and a secondary index.
+func shopTables() []Table {
+	return []Table{
+		{
+			TableName:  "users",
+			PrimaryKey: []string{"id"},
+			Fields: []Field{
+				{Name: "id", Type: "bigint unsigned", PrimaryKey: true, AutoIncrement: true},
+				{Name: "email", Type: "varchar(255)", Unique: true},
+				{Name: "status", Type: "enum('active','banned')", Default: "'active'"},
+				{Name: "created_at", Type: "datetime", Default: "CURRENT_TIMESTAMP"},
+				{Name: "bio", Type: "text", Nullable: true},
+			},
+		},
+		{
+			TableName:  "orders",
+			PrimaryKey: []string{"id"},
+			Fields: []Field{
+				{Name: "id", Type: "int", PrimaryKey: true},
+				{Name: "user_id", Type: "bigint unsigned"},
+				{Name: "total", Type: "decimal(10,2)", Default: "'0.00'"},
+			},
+			Indexes: []parser.Index{{Name: "idx_placed", Columns: []parser.IndexColumn{{Name: "user_id"}, {Name: "total"}}}},
+			ForeignKeys: []parser.ForeignKey{{
+				Columns: []string{"user_id"}, ReferencedTable: "users", ReferencedColumns: []string{"id"}, OnDelete: "CASCADE",
+			}},
+		},
+	}
+}
+
+func TestGeneratePostgreSQLSchema(t *testing.T) {
+	want := `-- Enum types
+CREATE TYPE users_status_enum AS ENUM ('active', 'banned');
+
+CREATE TABLE users (
+  id BIGSERIAL NOT NULL PRIMARY KEY,
+  email VARCHAR(255) NOT NULL,
+  status users_status_enum NOT NULL DEFAULT 'active',
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  bio TEXT,
+  CONSTRAINT idx_users_email UNIQUE (email)
+);
+
+CREATE TABLE orders (
+  id INTEGER NOT NULL PRIMARY KEY,
+  user_id NUMERIC(20) NOT NULL,
+  total NUMERIC(10,2) NOT NULL DEFAULT 0.00
+);
+
+-- Foreign Keys
+ALTER TABLE orders ADD CONSTRAINT fk_orders_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+-- Indexes
+CREATE INDEX IF NOT EXISTS idx_placed ON orders (user_id, total);
+
+`
+	got, err := GeneratePostgreSQLSchema(shopTables())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGeneratePostgreSQLSchemaIfExists(t *testing.T) {
+	tests := []struct {
+		ifExists string
+		want     []string
+	}{
+		{IfExistsReplace, []string{
+			"DROP TABLE IF EXISTS users CASCADE;\nDROP TABLE IF EXISTS orders CASCADE;\nDROP TYPE IF EXISTS users_status_enum CASCADE;\n",
+			"\nCREATE TABLE users (\n",
+		}},
+		{IfExistsSkip, []string{
+			"DO $$ BEGIN\n  CREATE TYPE users_status_enum AS ENUM ('active', 'banned');\nEXCEPTION WHEN duplicate_object THEN NULL;\nEND $$;",
+			"\nCREATE TABLE IF NOT EXISTS users (\n",
+			"DO $$ BEGIN\n  ALTER TABLE orders ADD CONSTRAINT fk_orders_user_id",
+		}},
+		{IfExistsTruncate, []string{
+			"\nCREATE TABLE IF NOT EXISTS orders (\n",
+			"TRUNCATE TABLE users, orders RESTART IDENTITY;\n",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ifExists, func(t *testing.T) {
+			got, err := generatePostgreSQLSchema(shopTables(), tt.ifExists, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("missing %q in:\n%s", w, got)
+				}
+			}
+		})
+	}
+}
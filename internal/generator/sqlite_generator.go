@@ -1,9 +1,178 @@
 package generator
 
-type SQLiteGenerator struct{}
+import (
+	"bigdataimporter/internal/parser"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MySQLToSQLiteType maps a MySQL column type to the SQLite type affinity
+// that stores it without loss.
+func MySQLToSQLiteType(mysqlType string) string {
+	t := strings.ToLower(mysqlType)
+	switch {
+	case isSpatialType(mysqlBaseType(t)):
+		return "BLOB"
+	case strings.Contains(t, "int"), strings.HasPrefix(t, "bit"), strings.HasPrefix(t, "bool"):
+		return "INTEGER"
+	case strings.Contains(t, "char"), strings.Contains(t, "text"),
+		strings.HasPrefix(t, "enum"), strings.HasPrefix(t, "set"), strings.HasPrefix(t, "json"):
+		return "TEXT"
+	case strings.Contains(t, "blob"), strings.Contains(t, "binary"):
+		return "BLOB"
+	case strings.Contains(t, "float"), strings.Contains(t, "double"), strings.Contains(t, "real"):
+		return "REAL"
+	case strings.Contains(t, "decimal"), strings.Contains(t, "numeric"):
+		return "NUMERIC"
+	default:
+		return "TEXT"
+	}
+}
+
+func QuoteSQLiteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func sqliteIdentList(names []string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = QuoteSQLiteIdent(n)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func GenerateSQLiteSchema(tables []Table) (string, error) {
+	return generateSQLiteSchema(tables, nil)
+}
+
+func generateSQLiteSchema(tables []Table, types *TypeMap) (string, error) {
+	var sb strings.Builder
+	var allIndexes []string
+	usedNames := map[string]bool{}
+	for _, table := range tables {
+		usedNames[strings.ToLower(table.TableName)] = true
+	}
+
+	for _, table := range tables {
+		if table.TableName == "" {
+			continue
+		}
+
+		// SQLite only allows AUTOINCREMENT on a single INTEGER PRIMARY KEY column.
+		singlePK := len(table.PrimaryKey) <= 1
+
+		var defs []string
+		var fks []string
+		for _, f := range table.Fields {
+			ct := f.ColumnType()
+			sqliteType, builtin := types.lookup(DialectSQLite, table.TableName, f.Name, ct, f.AutoIncrement)
+			col := fmt.Sprintf("  %s %s", QuoteSQLiteIdent(f.Name), sqliteType)
+
+			if f.PrimaryKey && singlePK {
+				if f.AutoIncrement && sqliteType == "INTEGER" {
+					col += " PRIMARY KEY AUTOINCREMENT"
+				} else {
+					col += " PRIMARY KEY"
+				}
+			}
+			if !f.Nullable && !(f.PrimaryKey && f.AutoIncrement && singlePK) {
+				col += " NOT NULL"
+			}
+			if def := sqliteDefault(f.Default, sqliteType); def != "" {
+				col += " DEFAULT " + def
+			}
+			if builtin && ct.Base == "enum" && len(ct.Values) > 0 {
+				col += fmt.Sprintf(" CHECK (%s IN (%s))", QuoteSQLiteIdent(f.Name), postgresQuoteList(ct.Values))
+			}
+			defs = append(defs, col)
+		}
+
+		for _, fk := range tableForeignKeys(table) {
+			fks = append(fks, fmt.Sprintf("  CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s(%s)%s",
+				QuoteSQLiteIdent(foreignKeyName(table.TableName, fk)), sqliteIdentList(fk.Columns),
+				QuoteSQLiteIdent(fk.ReferencedTable), sqliteIdentList(fk.ReferencedColumns), foreignKeyActions(fk, true)))
+		}
+
+		for _, ix := range tableIndexes(table) {
+			allIndexes = append(allIndexes, sqliteIndex(usedNames, table.TableName, ix))
+		}
+
+		if !singlePK {
+			quoted := make([]string, len(table.PrimaryKey))
+			for i, pk := range table.PrimaryKey {
+				quoted[i] = QuoteSQLiteIdent(pk)
+			}
+			defs = append(defs, fmt.Sprintf("  PRIMARY KEY (%s)", strings.Join(quoted, ", ")))
+		}
+		// SQLite cannot add constraints with ALTER TABLE, so foreign keys are inlined.
+		defs = append(defs, fks...)
+
+		sb.WriteString(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n", QuoteSQLiteIdent(table.TableName)))
+		sb.WriteString(strings.Join(defs, ",\n"))
+		sb.WriteString("\n);\n\n")
+	}
+
+	if len(allIndexes) > 0 {
+		sb.WriteString("-- Indexes\n")
+		for _, i := range allIndexes {
+			sb.WriteString(i + "\n")
+		}
+		sb.WriteString("\n")
+	}
+
+	return sb.String(), nil
+}
+
+// sqliteIndex creates a key of a table. SQLite has no full-text or spatial
+// index outside its virtual table modules, so those keys are noted only.
+func sqliteIndex(used map[string]bool, table string, ix parser.Index) string {
+	if ix.Type != "" {
+		return fmt.Sprintf("-- %s key %s on %s(%s) skipped: not supported by SQLite",
+			strings.ToUpper(ix.Type), indexName(table, ix), table, strings.Join(ix.ColumnNames(), ", "))
+	}
+	kind := "INDEX"
+	if ix.Unique {
+		kind = "UNIQUE INDEX"
+	}
+	return fmt.Sprintf("CREATE %s IF NOT EXISTS %s ON %s (%s);", kind,
+		QuoteSQLiteIdent(schemaIndexName(used, table, ix)), QuoteSQLiteIdent(table), sqliteIdentList(ix.ColumnNames()))
+}
+
+func sqliteDefault(defRaw, sqliteType string) string {
+	defRaw = strings.TrimSpace(defRaw)
+	def := strings.ToLower(defRaw)
+	switch {
+	case defRaw == "":
+		return ""
+	case def == "current_timestamp()" || def == "current_timestamp" || def == "now()":
+		return "CURRENT_TIMESTAMP"
+	case def == "null":
+		return "NULL"
+	case isBitLiteral(def):
+		if n, ok := bitLiteral(def); ok && sqliteType != "BLOB" {
+			return strconv.FormatUint(n, 10)
+		}
+		if def[0] == 'x' {
+			return "X" + defRaw[1:]
+		}
+		return ""
+	case sqliteType == "INTEGER" || sqliteType == "REAL" || sqliteType == "NUMERIC":
+		if _, err := strconv.ParseFloat(strings.Trim(defRaw, "'"), 64); err == nil {
+			return strings.Trim(defRaw, "'")
+		}
+		fallthrough
+	default:
+		return "'" + strings.ReplaceAll(strings.Trim(defRaw, "'"), "'", "''") + "'"
+	}
+}
+
+type SQLiteGenerator struct {
+	Types *TypeMap // type overrides; built-in mapping when nil
+}
 
 func (s *SQLiteGenerator) GenerateSchema(tables []Table) (string, error) {
-	return "// SQLite schema generation not implemented yet\n", nil
+	return generateSQLiteSchema(tables, s.Types)
 }
 
 func (s *SQLiteGenerator) ImportData(tables []Table) error {
@@ -0,0 +1,55 @@
+package generator
+
+import "testing"
+
+func TestGenerateSQLiteSchema(t *testing.T) {
+	want := `CREATE TABLE IF NOT EXISTS "users" (
+  "id" INTEGER PRIMARY KEY AUTOINCREMENT,
+  "email" TEXT NOT NULL,
+  "status" TEXT NOT NULL DEFAULT 'active' CHECK ("status" IN ('active', 'banned')),
+  "created_at" TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "bio" TEXT
+);
+
+CREATE TABLE IF NOT EXISTS "orders" (
+  "id" INTEGER PRIMARY KEY NOT NULL,
+  "user_id" INTEGER NOT NULL,
+  "total" NUMERIC NOT NULL DEFAULT 0.00,
+  CONSTRAINT "fk_orders_user_id" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE
+);
+
+-- Indexes
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_email" ON "users" ("email");
+CREATE INDEX IF NOT EXISTS "idx_placed" ON "orders" ("user_id", "total");
+
+`
+	got, err := GenerateSQLiteSchema(shopTables())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMySQLToSQLiteType(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"bigint unsigned", "INTEGER"},
+		{"tinyint(1)", "INTEGER"},
+		{"bit(8)", "INTEGER"},
+		{"varchar(40)", "TEXT"},
+		{"enum('a','b')", "TEXT"},
+		{"json", "TEXT"},
+		{"longblob", "BLOB"},
+		{"varbinary(16)", "BLOB"},
+		{"point", "BLOB"},
+		{"double", "REAL"},
+		{"decimal(10,2)", "NUMERIC"},
+		{"datetime", "TEXT"},
+	}
+	for _, tt := range tests {
+		if got := MySQLToSQLiteType(tt.in); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package generator
+
+import (
+	"bigdataimporter/internal/config"
+	"bigdataimporter/internal/parser"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Target dialects of the type mapping.
+const (
+	DialectPostgres = "postgres"
+	DialectMySQL    = "mysql"
+	DialectSQLite   = "sqlite"
+	DialectMongo    = "mongo"
+)
+
+// Dialect returns the dialect of a target name, or "" for an unknown one.
+func Dialect(target string) string {
+	switch strings.ToLower(target) {
+	case "postgres", "postgresql":
+		return DialectPostgres
+	case "mysql", "mariadb":
+		return DialectMySQL
+	case "sqlite":
+		return DialectSQLite
+	case "mongo", "mongodb":
+		return DialectMongo
+	}
+	return ""
+}
+
+// Ways of creating MySQL ENUM columns in PostgreSQL.
+const (
+	PostgresEnumType  = "type"  // CREATE TYPE <table>_<column>_enum AS ENUM
+	PostgresEnumCheck = "check" // TEXT with a CHECK constraint
+)
+
+// TypeMap maps the column types of parsed tables onto target dialects. The
+// type_mappings rules of config.yaml are tried first, in order; a column no
+// rule matches gets the built-in mapping of its target. A nil TypeMap only
+// has the built-in mapping.
+type TypeMap struct {
+	source        string
+	rules         []config.TypeMapping
+	postgresEnums string
+}
+
+// NewTypeMap keeps the rules of cfg that apply to input of the given format.
+func NewTypeMap(source string, cfg *config.Config) (*TypeMap, error) {
+	m := &TypeMap{source: source, postgresEnums: cfg.Postgres.Enums}
+	switch m.postgresEnums {
+	case "", PostgresEnumType, PostgresEnumCheck:
+	default:
+		return nil, fmt.Errorf("postgres.enums: unknown value %q (type|check)", m.postgresEnums)
+	}
+	for i, r := range cfg.TypeMappings {
+		if Dialect(r.Target) == "" {
+			return nil, fmt.Errorf("type_mappings[%d]: unknown target %q", i, r.Target)
+		}
+		if strings.TrimSpace(r.To) == "" {
+			return nil, fmt.Errorf("type_mappings[%d]: missing to", i)
+		}
+		for _, pattern := range []string{r.Table, r.Column} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("type_mappings[%d]: bad pattern %q", i, pattern)
+			}
+		}
+		if r.Source != "" && !strings.EqualFold(r.Source, source) {
+			continue
+		}
+		r.Target = Dialect(r.Target)
+		m.rules = append(m.rules, r)
+	}
+	return m, nil
+}
+
+// Column returns the type of a column in the target dialect.
+func (m *TypeMap) Column(target, table, column string, ct parser.ColumnType, autoIncrement bool) string {
+	t, _ := m.lookup(target, table, column, ct, autoIncrement)
+	return t
+}
+
+// lookup is Column that also reports whether the built-in mapping was used,
+// which generators need to know before adding constraints of their own.
+func (m *TypeMap) lookup(target, table, column string, ct parser.ColumnType, autoIncrement bool) (string, bool) {
+	dialect := Dialect(target)
+	if m != nil {
+		for _, r := range m.rules {
+			if r.Target == dialect && ruleMatches(r, ct, table, column) {
+				return r.To, false
+			}
+		}
+	}
+
+	switch dialect {
+	case DialectPostgres:
+		if ct.Base == "enum" && len(ct.Values) > 0 && m.enumStyle() == PostgresEnumType {
+			return postgresEnumName(table, column), true
+		}
+		return postgresType(ct, autoIncrement), true
+	case DialectMySQL:
+		return ToMySQLType(ct.String()), true
+	case DialectSQLite:
+		return MySQLToSQLiteType(ct.String()), true
+	case DialectMongo:
+		return MySQLToBSONType(ct.String()), true
+	}
+	return ct.String(), true
+}
+
+func (m *TypeMap) enumStyle() string {
+	if m == nil || m.postgresEnums == "" {
+		return PostgresEnumType
+	}
+	return m.postgresEnums
+}
+
+func ruleMatches(r config.TypeMapping, ct parser.ColumnType, table, column string) bool {
+	if r.Type != "" && !strings.EqualFold(r.Type, ct.Base) {
+		return false
+	}
+	if r.Length != nil && *r.Length != ct.Length {
+		return false
+	}
+	if r.Precision != nil && *r.Precision != ct.Precision {
+		return false
+	}
+	if r.Scale != nil && *r.Scale != ct.Scale {
+		return false
+	}
+	if r.Unsigned != nil && *r.Unsigned != ct.Unsigned {
+		return false
+	}
+	if ok, _ := path.Match(r.Table, table); r.Table != "" && !ok {
+		return false
+	}
+	if ok, _ := path.Match(r.Column, column); r.Column != "" && !ok {
+		return false
+	}
+	return true
+}
+
+// isSpatialType reports the MySQL spatial base types.
+func isSpatialType(base string) bool {
+	switch base {
+	case "geometry", "point", "linestring", "polygon", "multipoint", "multilinestring", "multipolygon", "geometrycollection":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package httpserver
+
+import (
+	"bigdataimporter/internal/worker"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// keepAliveInterval spaces the comments that keep idle proxies from closing
+// a quiet stream.
+const keepAliveInterval = 15 * time.Second
+
+// jobEvents streams the events of a job as Server-Sent Events: the current
+// state and progress first, then every stage change and progress update
+// until the job finishes or the client goes away.
+func jobEvents(w http.ResponseWriter, r *http.Request, id string) {
+	events, unsubscribe, err := worker.SubscribeJob(id)
+	if err != nil {
+		http.Error(w, "Job bulunamadı: "+id, http.StatusNotFound)
+		return
+	}
+	defer unsubscribe()
+
+	// The server's WriteTimeout would cut long streams.
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	status, _ := worker.GetJob(id)
+	writeEvent(w, worker.EventState, status)
+	if p, ok := worker.JobProgressOf(id); ok {
+		writeEvent(w, worker.EventProgress, p)
+	}
+	if err := rc.Flush(); err != nil || status.Finished() {
+		return
+	}
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case ev := <-events:
+			writeEvent(w, ev.Type, ev.Data)
+			if s, ok := ev.Data.(worker.JobStatus); ok && s.Finished() {
+				// Send the final counters along with the final state.
+				if p, ok := worker.JobProgressOf(id); ok {
+					writeEvent(w, worker.EventProgress, p)
+				}
+				rc.Flush()
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event string, data interface{}) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+}
@@ -0,0 +1,66 @@
+package httpserver
+
+import (
+	"bigdataimporter/internal/worker"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// JobsHandler serves GET /jobs and GET /jobs/{id} from the worker's job
+// registry, streams GET /jobs/{id}/events and cancels a job on
+// DELETE /jobs/{id}.
+func JobsHandler(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/jobs"), "/")
+
+	if jobID, ok := strings.CutSuffix(id, "/events"); ok && jobID != "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Yalnızca GET destekleniyor", http.StatusMethodNotAllowed)
+			return
+		}
+		jobEvents(w, r, jobID)
+		return
+	}
+
+	if r.Method == http.MethodDelete && id != "" {
+		cancelJob(w, id)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Yalnızca GET ve DELETE destekleniyor", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if id == "" {
+		writeJSON(w, http.StatusOK, worker.ListJobs())
+		return
+	}
+
+	job, ok := worker.GetJob(id)
+	if !ok {
+		http.Error(w, "Job bulunamadı: "+id, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// cancelJob answers 202 with the job's status once cancellation has been
+// requested; a running job reaches the cancelled state shortly after.
+func cancelJob(w http.ResponseWriter, id string) {
+	job, err := worker.CancelJob(id)
+	switch {
+	case errors.Is(err, worker.ErrJobNotFound):
+		http.Error(w, "Job bulunamadı: "+id, http.StatusNotFound)
+	case errors.Is(err, worker.ErrJobFinished):
+		http.Error(w, "Job zaten tamamlandı: "+job.State, http.StatusConflict)
+	default:
+		writeJSON(w, http.StatusAccepted, job)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
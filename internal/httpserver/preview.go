@@ -0,0 +1,157 @@
+package httpserver
+
+import (
+	"bigdataimporter/internal/executor"
+	"bigdataimporter/internal/parser"
+	"bigdataimporter/internal/worker"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type schemaPreview struct {
+	Source    string               `json:"source"`
+	Tables    []parser.ParsedTable `json:"tables"`
+	TotalRows int64                `json:"total_rows"`
+	Target    string               `json:"target,omitempty"`
+	DDL       string               `json:"ddl,omitempty"`
+}
+
+// SchemaPreviewHandler parses a dump and returns what the parser understood,
+// together with the DDL generated for the "to" target when one is given.
+// The input is an uploaded "file" (several for CSV), the input of a queued
+// job ("job_id") or a file already under uploads/ ("path"). No database is
+// touched and nothing is queued.
+func SchemaPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Yalnızca POST destekleniyor", http.StatusMethodNotAllowed)
+		return
+	}
+
+	const maxUploadSize = 1 << 30
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+
+	policy, ok := ifExistsPolicy(w, r)
+	if !ok {
+		return
+	}
+	target := r.FormValue("to")
+	job := worker.Job{
+		Target:        target,
+		EmbedChildren: r.FormValue("embed_children") == "true",
+		IfExists:      policy,
+	}
+	if target != "" && worker.SelectGenerator(job, nil) == nil {
+		http.Error(w, "Desteklenmeyen hedef: "+target, http.StatusBadRequest)
+		return
+	}
+
+	source := r.FormValue("from")
+	filePath, cleanup, status, err := previewInput(r, &source)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	defer cleanup()
+
+	if source == "" {
+		if source, err = parser.DetectFormat(filePath); err != nil {
+			http.Error(w, fmt.Sprintf("Format algılanamadı: %v", err), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	tables, _, cleanupParse, err := parser.ParseFile(r.Context(), filePath, source)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Parse hatası: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+	cleanupParse()
+
+	resp := schemaPreview{Source: source, Tables: tables, Target: target}
+	if resp.Tables == nil {
+		resp.Tables = []parser.ParsedTable{}
+	}
+	for _, t := range tables {
+		resp.TotalRows += t.RowCount
+	}
+
+	if target != "" && len(tables) > 0 {
+		// The type_mappings of the server's config apply as they would to
+		// a job.
+		types, err := executor.TypeMap("", source)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		ddl, err := worker.SelectGenerator(job, types).GenerateSchema(worker.GeneratorTables(tables))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Şema üretilemedi: %v", err), http.StatusUnprocessableEntity)
+			return
+		}
+		resp.DDL = ddl
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// previewInput resolves the file to preview. Uploads go to a temporary
+// directory that the returned function removes.
+func previewInput(r *http.Request, source *string) (string, func(), int, error) {
+	noop := func() {}
+
+	if r.MultipartForm != nil && len(r.MultipartForm.File["file"]) > 0 {
+		dir, err := os.MkdirTemp("", "preview-")
+		if err != nil {
+			return "", nil, http.StatusInternalServerError, fmt.Errorf("geçici klasör oluşturulamadı: %v", err)
+		}
+		cleanup := func() { os.RemoveAll(dir) }
+
+		headers := r.MultipartForm.File["file"]
+		var files []string
+		for _, header := range headers {
+			dstPath := filepath.Join(dir, filepath.Base(header.Filename))
+			if err := saveUpload(header, dstPath); err != nil {
+				cleanup()
+				return "", nil, http.StatusInternalServerError, fmt.Errorf("Dosya kopyalanamadı: %v", err)
+			}
+			files = append(files, dstPath)
+		}
+		// Several files are only meaningful as a directory of CSV tables.
+		if len(files) == 1 {
+			return files[0], cleanup, 0, nil
+		}
+		return dir, cleanup, 0, nil
+	}
+
+	if id := r.FormValue("job_id"); id != "" {
+		job, ok := worker.GetJob(id)
+		if !ok {
+			return "", nil, http.StatusNotFound, fmt.Errorf("Job bulunamadı: %s", id)
+		}
+		if *source == "" {
+			*source = job.Source
+		}
+		if _, err := os.Stat(job.FilePath); err != nil {
+			return "", nil, http.StatusGone, fmt.Errorf("Job dosyası artık yok: %s", job.FilePath)
+		}
+		return job.FilePath, noop, 0, nil
+	}
+
+	if p := r.FormValue("path"); p != "" {
+		// Only files under uploads/ can be referenced.
+		rel := filepath.Clean(filepath.FromSlash(strings.TrimPrefix(p, "uploads/")))
+		if filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", nil, http.StatusBadRequest, fmt.Errorf("Geçersiz dosya yolu: %s", p)
+		}
+		filePath := filepath.Join("uploads", rel)
+		if _, err := os.Stat(filePath); err != nil {
+			return "", nil, http.StatusNotFound, fmt.Errorf("Dosya bulunamadı: %s", p)
+		}
+		return filePath, noop, 0, nil
+	}
+
+	return "", nil, http.StatusBadRequest, fmt.Errorf("Eksik parametre: 'file', 'job_id' veya 'path'")
+}
@@ -1,6 +1,7 @@
 package httpserver
 
 import (
+	"bigdataimporter/internal/generator"
 	"bigdataimporter/internal/worker"
 	"encoding/json"
 	"fmt"
@@ -21,6 +22,11 @@ func UploadSQLHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	policy, ok := ifExistsPolicy(w, r)
+	if !ok {
+		return
+	}
+
 	file, header, err := r.FormFile("file")
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Dosya alınamadı: %v", err), http.StatusBadRequest)
@@ -47,9 +53,13 @@ func UploadSQLHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	job := worker.Job{
-		ID:       fmt.Sprintf("job-%d", time.Now().UnixNano()),
-		FilePath: dstPath,
-		Target:   target,
+		ID:            fmt.Sprintf("job-%d", time.Now().UnixNano()),
+		FilePath:      dstPath,
+		Source:        r.FormValue("from"),
+		Target:        target,
+		EmbedChildren: r.FormValue("embed_children") == "true",
+		DryRun:        r.FormValue("dry_run") == "true",
+		IfExists:      policy,
 	}
 
 	worker.Enqueue(job)
@@ -59,8 +69,21 @@ func UploadSQLHandler(w http.ResponseWriter, r *http.Request) {
 		"job_id":    job.ID,
 		"file_path": dstPath,
 		"target":    target,
+		"dry_run":   job.DryRun,
+		"if_exists": job.IfExists,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
+
+// ifExistsPolicy reads the optional if_exists field; an unknown policy is
+// answered with 400.
+func ifExistsPolicy(w http.ResponseWriter, r *http.Request) (string, bool) {
+	policy := r.FormValue("if_exists")
+	if !generator.ValidIfExists(policy) {
+		http.Error(w, fmt.Sprintf("Geçersiz if_exists: %s (%s)", policy, generator.IfExistsPolicies()), http.StatusBadRequest)
+		return "", false
+	}
+	return policy, true
+}
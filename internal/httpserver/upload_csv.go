@@ -0,0 +1,107 @@
+package httpserver
+
+import (
+	"bigdataimporter/internal/parser"
+	"bigdataimporter/internal/worker"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// UploadCSVHandler accepts one or more CSV/TSV files, or a single zip archive
+// of them, in the "file" field. Every file becomes one table, named after the
+// file, and all of them are imported as a single job.
+func UploadCSVHandler(w http.ResponseWriter, r *http.Request) {
+	const maxUploadSize = 1 << 30
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+
+	target := r.FormValue("to")
+	if target == "" {
+		http.Error(w, "Eksik parametre: 'to' (örnek: postgres)", http.StatusBadRequest)
+		return
+	}
+
+	policy, ok := ifExistsPolicy(w, r)
+	if !ok {
+		return
+	}
+
+	if r.MultipartForm == nil || len(r.MultipartForm.File["file"]) == 0 {
+		http.Error(w, "Dosya alınamadı: 'file' alanı boş", http.StatusBadRequest)
+		return
+	}
+
+	jobID := fmt.Sprintf("job-%d", time.Now().UnixNano())
+	dstDir := filepath.Join("uploads", jobID)
+	if err := os.MkdirAll(dstDir, os.ModePerm); err != nil {
+		http.Error(w, "uploads klasörü oluşturulamadı", http.StatusInternalServerError)
+		return
+	}
+
+	var files []string
+	for _, header := range r.MultipartForm.File["file"] {
+		dstPath := filepath.Join(dstDir, filepath.Base(header.Filename))
+		if err := saveUpload(header, dstPath); err != nil {
+			http.Error(w, fmt.Sprintf("Dosya kopyalanamadı: %v", err), http.StatusInternalServerError)
+			return
+		}
+		files = append(files, dstPath)
+	}
+
+	// A single zip archive is read as it is; loose files as a directory.
+	filePath := dstDir
+	if len(files) == 1 && filepath.Ext(files[0]) == ".zip" {
+		filePath = files[0]
+	}
+
+	format := parser.FormatCSV
+	if r.FormValue("from") == parser.FormatTSV {
+		format = parser.FormatTSV
+	}
+
+	job := worker.Job{
+		ID:       jobID,
+		FilePath: filePath,
+		Source:   format,
+		Target:   target,
+		DryRun:   r.FormValue("dry_run") == "true",
+		IfExists: policy,
+	}
+
+	worker.Enqueue(job)
+
+	resp := map[string]interface{}{
+		"message":   "Dosyalar alındı ve işleme kuyruğa eklendi",
+		"job_id":    job.ID,
+		"file_path": filePath,
+		"files":     files,
+		"target":    target,
+		"dry_run":   job.DryRun,
+		"if_exists": job.IfExists,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func saveUpload(header *multipart.FileHeader, dstPath string) error {
+	src, err := header.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	_, err = io.Copy(dst, src)
+	return err
+}
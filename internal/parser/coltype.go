@@ -0,0 +1,179 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ColumnType is a MySQL column type taken apart. Field.Type keeps the full
+// spelling; generators map from this model so arguments and modifiers are
+// not lost.
+type ColumnType struct {
+	Base      string   `json:"base"`
+	Length    int      `json:"length,omitempty"`    // character, binary, bit and integer display widths
+	Precision int      `json:"precision,omitempty"` // digits of decimal and float, fractional seconds of time types
+	Scale     int      `json:"scale,omitempty"`
+	Unsigned  bool     `json:"unsigned,omitempty"`
+	Zerofill  bool     `json:"zerofill,omitempty"`
+	Charset   string   `json:"charset,omitempty"`
+	Collation string   `json:"collation,omitempty"`
+	Values    []string `json:"values,omitempty"` // members of enum and set
+}
+
+// ParseColumnType reads a MySQL type such as "decimal(10,2) unsigned" or
+// "enum('a','b') character set utf8mb4".
+func ParseColumnType(mysqlType string) ColumnType {
+	s := strings.TrimSpace(mysqlType)
+	end := 0
+	for end < len(s) && isWordByte(s[end]) {
+		end++
+	}
+	ct := ColumnType{Base: strings.ToLower(s[:end])}
+	rest := strings.TrimSpace(s[end:])
+
+	var args []string
+	if strings.HasPrefix(rest, "(") {
+		closeIdx := closingParen(rest, 0)
+		if closeIdx < 0 {
+			closeIdx = len(rest) - 1
+		}
+		args = splitTopLevel(rest[1:closeIdx])
+		rest = rest[closeIdx+1:]
+	}
+
+	switch ct.Base {
+	case "enum", "set":
+		for _, a := range args {
+			ct.Values = append(ct.Values, unquoteTypeValue(strings.TrimSpace(a)))
+		}
+	case "decimal", "numeric", "dec", "fixed", "float", "double", "real",
+		"datetime", "timestamp", "time":
+		ct.Precision, ct.Scale = typeArg(args, 0), typeArg(args, 1)
+	default:
+		ct.Length = typeArg(args, 0)
+	}
+
+	words := strings.Fields(rest)
+	for i := 0; i < len(words); i++ {
+		next := ""
+		if i+1 < len(words) {
+			next = strings.Trim(words[i+1], "`\"'")
+		}
+		switch strings.ToLower(words[i]) {
+		case "unsigned":
+			ct.Unsigned = true
+		case "zerofill":
+			// ZEROFILL implies UNSIGNED in MySQL.
+			ct.Zerofill, ct.Unsigned = true, true
+		case "charset":
+			ct.Charset = next
+			i++
+		case "character":
+			if i+2 < len(words) && strings.EqualFold(words[i+1], "set") {
+				ct.Charset = strings.Trim(words[i+2], "`\"'")
+				i += 2
+			}
+		case "collate":
+			ct.Collation = next
+			i++
+		}
+	}
+	return ct
+}
+
+// String spells the type the way MySQL does.
+func (c ColumnType) String() string {
+	var sb strings.Builder
+	sb.WriteString(c.Base)
+	switch {
+	case len(c.Values) > 0:
+		quoted := make([]string, len(c.Values))
+		for i, v := range c.Values {
+			quoted[i] = "'" + strings.ReplaceAll(v, "'", "''") + "'"
+		}
+		sb.WriteString("(" + strings.Join(quoted, ",") + ")")
+	case c.Precision > 0 && c.Scale > 0:
+		sb.WriteString("(" + strconv.Itoa(c.Precision) + "," + strconv.Itoa(c.Scale) + ")")
+	case c.Precision > 0:
+		sb.WriteString("(" + strconv.Itoa(c.Precision) + ")")
+	case c.Length > 0:
+		sb.WriteString("(" + strconv.Itoa(c.Length) + ")")
+	}
+	if c.Unsigned {
+		sb.WriteString(" unsigned")
+	}
+	if c.Zerofill {
+		sb.WriteString(" zerofill")
+	}
+	return sb.String()
+}
+
+// ColumnType returns the structured type of a field, reading it from Type
+// when the parser did not fill it in.
+func (f Field) ColumnType() ColumnType {
+	if f.TypeInfo.Base != "" {
+		return f.TypeInfo
+	}
+	return ParseColumnType(f.Type)
+}
+
+func typeArg(args []string, i int) int {
+	if i >= len(args) {
+		return 0
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(args[i]))
+	return n
+}
+
+// unquoteTypeValue reads one quoted enum or set member.
+func unquoteTypeValue(s string) string {
+	if len(s) < 2 || (s[0] != '\'' && s[0] != '"') || s[len(s)-1] != s[0] {
+		return s
+	}
+	q := s[0]
+	s = s[1 : len(s)-1]
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == q && i+1 < len(s) && s[i+1] == q:
+			i++
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// readColumnType splits what follows the name in a MySQL column definition
+// into the type, with its arguments and sign modifiers, and the rest.
+func readColumnType(def string) (string, string) {
+	def = strings.TrimSpace(def)
+	end := 0
+	for end < len(def) && isWordByte(def[end]) {
+		end++
+	}
+	if end == 0 {
+		return "", def
+	}
+	if end < len(def) && def[end] == '(' {
+		closeIdx := closingParen(def, end)
+		if closeIdx < 0 {
+			return def, ""
+		}
+		end = closeIdx + 1
+	}
+	for {
+		rest := strings.TrimLeft(def[end:], " \t")
+		word := rest
+		if i := strings.IndexAny(rest, " \t,"); i >= 0 {
+			word = rest[:i]
+		}
+		switch strings.ToLower(word) {
+		case "unsigned", "signed", "zerofill":
+			end = len(def) - len(rest) + len(word)
+			continue
+		}
+		return def[:end], def[end:]
+	}
+}
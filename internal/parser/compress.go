@@ -0,0 +1,287 @@
+package parser
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Compression formats recognized by their magic bytes.
+const (
+	compressionNone  = ""
+	compressionGzip  = "gzip"
+	compressionZstd  = "zstd"
+	compressionBzip2 = "bzip2"
+	compressionXz    = "xz"
+	compressionZip   = "zip"
+)
+
+func compressionOf(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return compressionGzip
+	case bytes.HasPrefix(head, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return compressionZstd
+	case bytes.HasPrefix(head, []byte("BZh")):
+		return compressionBzip2
+	case bytes.HasPrefix(head, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}):
+		return compressionXz
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		return compressionZip
+	default:
+		return compressionNone
+	}
+}
+
+// fileCompression sniffs the compression format of a file on disk.
+func fileCompression(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return compressionNone
+	}
+	defer file.Close()
+
+	head := make([]byte, 6)
+	n, _ := io.ReadFull(file, head)
+	return compressionOf(head[:n])
+}
+
+// inputExt returns the extension of a file name that tells its content,
+// looking through a compression suffix: "dump.sql.gz" gives ".sql".
+func inputExt(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	switch ext {
+	case ".gz", ".gzip", ".zst", ".zstd", ".bz2", ".xz":
+		return strings.ToLower(filepath.Ext(strings.TrimSuffix(name, filepath.Ext(name))))
+	}
+	return ext
+}
+
+// inputBase returns the file name without its content and compression
+// extensions.
+func inputBase(name string) string {
+	base := filepath.Base(name)
+	if ext := filepath.Ext(base); inputExt(base) != strings.ToLower(ext) {
+		base = strings.TrimSuffix(base, ext)
+	}
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+type multiCloser struct {
+	io.Reader
+	closers []func() error
+}
+
+func (m *multiCloser) Close() error {
+	var first error
+	for i := len(m.closers) - 1; i >= 0; i-- {
+		if err := m.closers[i](); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// openInput opens an input file and decompresses gzip, zstd, bzip2 and xz
+// content on the fly. A zip archive reads as the concatenation of its .sql
+// members (or of all members when there are none), in name order.
+func openInput(ctx context.Context, path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReaderSize(&ctxReader{ctx: ctx, r: file, read: ProgressFrom(ctx).Read}, 1<<20)
+	head, _ := br.Peek(6)
+	rc := &multiCloser{Reader: br, closers: []func() error{file.Close}}
+
+	switch compressionOf(head) {
+	case compressionGzip:
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("gzip error: %v", err)
+		}
+		rc.Reader = gz
+		rc.closers = append(rc.closers, gz.Close)
+	case compressionZstd:
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("zstd error: %v", err)
+		}
+		rc.Reader = zr
+		rc.closers = append(rc.closers, func() error { zr.Close(); return nil })
+	case compressionBzip2:
+		rc.Reader = bzip2.NewReader(br)
+	case compressionXz:
+		xr, err := xz.NewReader(br)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("xz error: %v", err)
+		}
+		rc.Reader = xr
+	case compressionZip:
+		file.Close()
+		return openZipMembers(ctx, path)
+	}
+	return rc, nil
+}
+
+// openZip opens a zip archive whose reads count as progress of ctx. The
+// returned function closes the file.
+func openZip(ctx context.Context, path string) (*zip.Reader, func() error, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	archive, err := zip.NewReader(&countingReaderAt{r: file, read: ProgressFrom(ctx).Read}, info.Size())
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("zip open error: %v", err)
+	}
+	return archive, file.Close, nil
+}
+
+func openZipMembers(ctx context.Context, path string) (io.ReadCloser, error) {
+	archive, closeFn, err := openZip(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var members, sqlMembers []*zip.File
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() || strings.HasPrefix(filepath.Base(f.Name), ".") {
+			continue
+		}
+		members = append(members, f)
+		if inputExt(f.Name) == ".sql" {
+			sqlMembers = append(sqlMembers, f)
+		}
+	}
+	if len(sqlMembers) > 0 {
+		members = sqlMembers
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Name < members[j].Name })
+
+	var readers []io.Reader
+	for _, f := range members {
+		// The newline keeps a trailing comment of one member from
+		// swallowing the first line of the next.
+		readers = append(readers, &zipMemberReader{file: f}, strings.NewReader("\n"))
+	}
+	return &multiCloser{Reader: &ctxReader{ctx: ctx, r: io.MultiReader(readers...)}, closers: []func() error{closeFn}}, nil
+}
+
+// ctxReader fails reads once its context is done, which stops any reader
+// built on top of it at the next buffer refill. When read is set it is
+// told how many bytes every read returned.
+type ctxReader struct {
+	ctx  context.Context
+	r    io.Reader
+	read func(int64)
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := c.r.Read(p)
+	if c.read != nil {
+		c.read(int64(n))
+	}
+	return n, err
+}
+
+// zipMemberReader opens a zip member on first read and closes it at its
+// end, so only one member is open at a time.
+type zipMemberReader struct {
+	file *zip.File
+	rc   io.ReadCloser
+	done bool
+}
+
+func (z *zipMemberReader) Read(p []byte) (int, error) {
+	if z.done {
+		return 0, io.EOF
+	}
+	if z.rc == nil {
+		rc, err := z.file.Open()
+		if err != nil {
+			return 0, fmt.Errorf("%s: %v", z.file.Name, err)
+		}
+		z.rc = rc
+	}
+	n, err := z.rc.Read(p)
+	if err == io.EOF {
+		z.rc.Close()
+		z.done = true
+	}
+	return n, err
+}
+
+// zipHasCSV reports archives holding CSV/TSV files, which are read one table
+// per member instead of as one stream.
+func zipHasCSV(path string) bool {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return false
+	}
+	defer archive.Close()
+
+	for _, f := range archive.File {
+		if !f.FileInfo().IsDir() && isCSVName(f.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// decompressedCopy writes the decompressed content of a compressed file to a
+// temporary file and returns its path, with a function that removes it;
+// readers that need random access, such as SQLite, work on the copy.
+// Uncompressed files are returned as they are, with a no-op cleanup.
+func decompressedCopy(ctx context.Context, path, ext string) (string, func(), error) {
+	switch fileCompression(path) {
+	case compressionNone, compressionZip:
+		return path, func() {}, nil
+	}
+
+	src, err := openInput(ctx, path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer src.Close()
+
+	dst, err := os.CreateTemp("", inputBase(path)+"-*"+ext)
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.Remove(dst.Name()) }
+	_, err = io.Copy(dst, src)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("decompress error: %v", err)
+	}
+	return dst.Name(), cleanup, nil
+}
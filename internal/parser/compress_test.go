@@ -0,0 +1,227 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+const compressedDump = "INSERT INTO t VALUES (1);\n"
+
+// bzip2Dump is compressedDump packed by bzip2 -9; the standard library has
+// no bzip2 writer.
+var bzip2Dump = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x70, 0xe3, 0xf1, 0x02, 0x00, 0x00,
+	0x06, 0x5e, 0x80, 0x00, 0x10, 0x40, 0x60, 0x20, 0x08, 0x22, 0x25, 0x9f, 0x00, 0x04, 0x00, 0x20,
+	0x00, 0x22, 0x20, 0x18, 0x8c, 0xd4, 0x7a, 0x85, 0x34, 0xc8, 0xc4, 0xc4, 0xc4, 0xcc, 0x82, 0x34,
+	0xec, 0xe5, 0x8f, 0x16, 0x02, 0x40, 0x4f, 0x1a, 0x95, 0x4f, 0x8b, 0xb9, 0x22, 0x9c, 0x28, 0x48,
+	0x38, 0x71, 0xf8, 0x81, 0x00,
+}
+
+// compressed packs compressedDump in the given format.
+func compressed(t *testing.T, format string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	var err error
+	switch format {
+	case compressionNone:
+		return []byte(compressedDump)
+	case compressionBzip2:
+		return bzip2Dump
+	case compressionGzip:
+		w = gzip.NewWriter(&buf)
+	case compressionZstd:
+		w, err = zstd.NewWriter(&buf)
+	case compressionXz:
+		w, err = xz.NewWriter(&buf)
+	case compressionZip:
+		return zipped(t, map[string]string{"dump.sql": compressedDump})
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(compressedDump)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zipped(t *testing.T, members map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range members {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func writeInput(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestOpenInput(t *testing.T) {
+	tests := []struct {
+		name string
+		data func(t *testing.T) []byte
+		want string
+	}{
+		{"plain", func(t *testing.T) []byte { return compressed(t, compressionNone) }, compressedDump},
+		{"gzip", func(t *testing.T) []byte { return compressed(t, compressionGzip) }, compressedDump},
+		{"zstd", func(t *testing.T) []byte { return compressed(t, compressionZstd) }, compressedDump},
+		{"bzip2", func(t *testing.T) []byte { return compressed(t, compressionBzip2) }, compressedDump},
+		{"xz", func(t *testing.T) []byte { return compressed(t, compressionXz) }, compressedDump},
+		{
+			name: "zip sql members in name order",
+			data: func(t *testing.T) []byte {
+				return zipped(t, map[string]string{"b.sql": "-- b", "a.sql": "-- a", "README.txt": "read me", ".hidden.sql": "-- hidden"})
+			},
+			want: "-- a\n-- b\n",
+		},
+		{
+			name: "zip without sql members",
+			data: func(t *testing.T) []byte { return zipped(t, map[string]string{"b.dump": "b", "a.dump": "a"}) },
+			want: "a\nb\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The name carries no hint: the format comes from the content.
+			path := writeInput(t, "input", tt.data(t))
+			r, err := openInput(context.Background(), path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := r.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenInputCorrupt(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"gzip", []byte{0x1f, 0x8b, 0x00, 0x00, 0x00, 0x00}, "gzip error"},
+		{"xz", []byte{0xfd, '7', 'z', 'X', 'Z', 0x00, 0x00}, "xz error"},
+		{"zip", []byte("PK\x03\x04 not a zip"), "zip open error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := openInput(context.Background(), writeInput(t, "input", tt.data))
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("got %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecompressedCopy(t *testing.T) {
+	tests := []struct {
+		format string
+		name   string
+		copied bool
+	}{
+		{compressionNone, "shop.sqlite", false},
+		{compressionZip, "shop.zip", false},
+		{compressionGzip, "shop.sqlite.gz", true},
+		{compressionZstd, "shop.sqlite.zst", true},
+		{compressionBzip2, "shop.sqlite.bz2", true},
+		{compressionXz, "shop.sqlite.xz", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmp := t.TempDir()
+			t.Setenv("TMPDIR", tmp)
+			path := writeInput(t, tt.name, compressed(t, tt.format))
+
+			got, cleanup, err := decompressedCopy(context.Background(), path, ".sqlite")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.copied {
+				if got != path {
+					t.Errorf("got %s, want the input itself", got)
+				}
+				cleanup()
+				if _, err := os.Stat(path); err != nil {
+					t.Errorf("cleanup removed the input: %v", err)
+				}
+				return
+			}
+
+			if filepath.Dir(got) != tmp || !strings.HasPrefix(filepath.Base(got), "shop-") || filepath.Ext(got) != ".sqlite" {
+				t.Errorf("got copy %s, want shop-*.sqlite in %s", got, tmp)
+			}
+			data, err := os.ReadFile(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != compressedDump {
+				t.Errorf("got %q, want %q", data, compressedDump)
+			}
+			cleanup()
+			if _, err := os.Stat(got); !os.IsNotExist(err) {
+				t.Errorf("copy left after cleanup: %v", err)
+			}
+		})
+	}
+}
+
+func TestDecompressedCopyCancelled(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	path := writeInput(t, "shop.sqlite.gz", compressed(t, compressionGzip))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := decompressedCopy(ctx, path, ".sqlite"); err == nil {
+		t.Fatal("got no error for a cancelled context")
+	}
+	left, err := os.ReadDir(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range left {
+		t.Errorf("temporary file left behind: %s", e.Name())
+	}
+}
@@ -0,0 +1,569 @@
+package parser
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// csvSampleRows bounds how many rows are remembered per column when looking
+// for a primary key candidate. Past it only integer columns whose values
+// keep increasing stay candidates, which needs no memory. Types are
+// inferred from every row.
+const csvSampleRows = 10000
+
+// csvTimeLayouts are the date and datetime spellings recognized in CSV
+// exports, most specific first.
+var csvTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02",
+}
+
+// csvEntry is one CSV file of the input; each entry becomes one table.
+type csvEntry struct {
+	table string
+	comma rune
+	open  func() (io.ReadCloser, error)
+}
+
+// csvEntries lists the tables of a CSV input: a single .csv/.tsv file, a
+// directory of them or a zip archive of them. The returned function
+// releases the archive.
+func csvEntries(ctx context.Context, path string, comma rune) ([]csvEntry, func() error, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if info.IsDir() {
+		files, err := os.ReadDir(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		var entries []csvEntry
+		for _, f := range files {
+			if f.IsDir() || !isCSVName(f.Name()) {
+				continue
+			}
+			entries = append(entries, fileCSVEntry(ctx, filepath.Join(path, f.Name()), comma))
+		}
+		uniqueCSVTables(entries)
+		return entries, func() error { return nil }, nil
+	}
+
+	if fileCompression(path) == compressionZip {
+		archive, closeFn, err := openZip(ctx, path)
+		if err != nil {
+			return nil, nil, err
+		}
+		var entries []csvEntry
+		for _, f := range archive.File {
+			if f.FileInfo().IsDir() || !isCSVName(f.Name) {
+				continue
+			}
+			member := f
+			entries = append(entries, csvEntry{
+				table: csvTableName(f.Name),
+				comma: csvComma(f.Name, comma),
+				open: func() (io.ReadCloser, error) {
+					rc, err := member.Open()
+					if err != nil {
+						return nil, err
+					}
+					return &multiCloser{Reader: &ctxReader{ctx: ctx, r: rc}, closers: []func() error{rc.Close}}, nil
+				},
+			})
+		}
+		uniqueCSVTables(entries)
+		return entries, closeFn, nil
+	}
+
+	return []csvEntry{fileCSVEntry(ctx, path, comma)}, func() error { return nil }, nil
+}
+
+// uniqueCSVTables suffixes the table of an entry whose name is already taken,
+// as a/users.csv and b/users.csv in one archive are, with _2, _3 and so on
+// the way csvHeader does for columns. Entries come in a stable order, so
+// the schema and data passes agree on the names.
+func uniqueCSVTables(entries []csvEntry) {
+	seen := map[string]bool{}
+	for i := range entries {
+		name := entries[i].table
+		for base, n := name, 2; seen[name]; n++ {
+			name = fmt.Sprintf("%s_%d", base, n)
+		}
+		if name != entries[i].table {
+			log.Printf("%s: table name already taken, using %s", entries[i].table, name)
+		}
+		seen[name] = true
+		entries[i].table = name
+	}
+}
+
+func fileCSVEntry(ctx context.Context, path string, comma rune) csvEntry {
+	return csvEntry{
+		table: csvTableName(path),
+		comma: csvComma(path, comma),
+		open:  func() (io.ReadCloser, error) { return openInput(ctx, path) },
+	}
+}
+
+func isCSVName(name string) bool {
+	switch inputExt(name) {
+	case ".csv", ".tsv", ".tab", ".txt":
+		return !strings.HasPrefix(filepath.Base(name), ".")
+	}
+	return false
+}
+
+// csvComma picks the separator of a file: tab for .tsv/.tab files unless
+// the caller asked for one explicitly.
+func csvComma(name string, comma rune) rune {
+	if comma != 0 {
+		return comma
+	}
+	switch inputExt(name) {
+	case ".tsv", ".tab":
+		return '\t'
+	}
+	return ','
+}
+
+func csvTableName(name string) string {
+	return columnIdent(inputBase(name))
+}
+
+// columnIdent turns a free-form header into an identifier every target
+// accepts unquoted: lower case letters, digits and underscores.
+func columnIdent(name string) string {
+	var sb strings.Builder
+	underscore := false
+	for _, r := range strings.TrimSpace(name) {
+		switch {
+		case r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			sb.WriteRune(unicode.ToLower(r))
+			underscore = false
+		case !underscore && sb.Len() > 0:
+			sb.WriteByte('_')
+			underscore = true
+		}
+	}
+	ident := strings.TrimSuffix(sb.String(), "_")
+	if ident == "" {
+		return ""
+	}
+	if ident[0] >= '0' && ident[0] <= '9' {
+		ident = "_" + ident
+	}
+	return ident
+}
+
+func newCSVReader(r io.Reader, comma rune) *csv.Reader {
+	reader := csv.NewReader(bufio.NewReaderSize(r, 1<<20))
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.ReuseRecord = true
+	return reader
+}
+
+// csvHeader reads the header row and returns unique column identifiers.
+func csvHeader(reader *csv.Reader) ([]string, error) {
+	record, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(record))
+	seen := map[string]bool{}
+	for i, h := range record {
+		if i == 0 {
+			h = strings.TrimPrefix(h, "\ufeff")
+		}
+		name := columnIdent(h)
+		if name == "" {
+			name = fmt.Sprintf("column_%d", i+1)
+		}
+		for base, n := name, 2; seen[name]; n++ {
+			name = fmt.Sprintf("%s_%d", base, n)
+		}
+		seen[name] = true
+		names[i] = name
+	}
+	return names, nil
+}
+
+// csvColumn collects what the values of one column have in common.
+type csvColumn struct {
+	notInt, notDecimal, notFloat, notBool, notDate, notDatetime bool
+
+	seen, nulls bool
+	big         bool
+	maxLen      int
+	intDigits   int
+	scale       int
+
+	sample map[string]struct{}
+	unique bool
+
+	// increasing holds while every value is an integer above the one before.
+	increasing bool
+	hasLast    bool
+	last       int64
+}
+
+func newCSVColumn() *csvColumn {
+	return &csvColumn{sample: map[string]struct{}{}, unique: true, increasing: true}
+}
+
+func (c *csvColumn) observe(v string, row int64) {
+	if v == "" {
+		c.nulls = true
+		return
+	}
+	c.seen = true
+
+	if c.increasing {
+		n, err := strconv.ParseInt(v, 10, 64)
+		c.increasing = err == nil && (!c.hasLast || c.last < n)
+		c.hasLast, c.last = true, n
+	}
+	switch {
+	case !c.unique:
+	case row <= csvSampleRows:
+		if _, dup := c.sample[v]; dup {
+			c.unique = false
+			c.sample = nil
+		} else {
+			c.sample[v] = struct{}{}
+		}
+	default:
+		// Uniqueness is only known past the sample for increasing values.
+		c.sample = nil
+		c.unique = c.increasing
+	}
+
+	if n := utf8.RuneCountInString(v); n > c.maxLen {
+		c.maxLen = n
+	}
+
+	if !c.notBool {
+		switch strings.ToLower(v) {
+		case "true", "false":
+		default:
+			c.notBool = true
+		}
+	}
+
+	// Leading zeros are kept as text: they are codes, not numbers.
+	if len(v) > 1 && v[0] == '0' && v[1] != '.' {
+		c.notInt, c.notDecimal, c.notFloat = true, true, true
+	}
+
+	if !c.notInt {
+		if n, err := strconv.ParseInt(v, 10, 64); err != nil {
+			c.notInt = true
+		} else if n > 1<<31-1 || n < -1<<31 {
+			c.big = true
+		}
+	}
+
+	if !c.notDecimal {
+		intPart, frac, ok := decimalParts(v)
+		if !ok {
+			c.notDecimal = true
+		} else {
+			c.intDigits = max(c.intDigits, len(intPart))
+			c.scale = max(c.scale, len(frac))
+		}
+	}
+
+	if !c.notFloat {
+		if _, err := strconv.ParseFloat(v, 64); err != nil {
+			c.notFloat = true
+		}
+	}
+
+	if !c.notDate || !c.notDatetime {
+		layout := csvTimeLayout(v)
+		if layout != "2006-01-02" {
+			c.notDate = true
+		}
+		if layout == "" {
+			c.notDatetime = true
+		}
+	}
+}
+
+// decimalParts splits a plain decimal literal into its digits before and
+// after the point.
+func decimalParts(v string) (string, string, bool) {
+	v = strings.TrimLeft(v, "+-")
+	intPart, frac, _ := strings.Cut(v, ".")
+	if intPart == "" && frac == "" {
+		return "", "", false
+	}
+	for _, s := range []string{intPart, frac} {
+		for i := 0; i < len(s); i++ {
+			if s[i] < '0' || s[i] > '9' {
+				return "", "", false
+			}
+		}
+	}
+	return strings.TrimLeft(intPart, "0"), frac, true
+}
+
+func csvTimeLayout(v string) string {
+	if len(v) < 10 || v[4] != '-' {
+		return ""
+	}
+	for _, layout := range csvTimeLayouts {
+		if _, err := time.Parse(layout, v); err == nil {
+			return layout
+		}
+	}
+	return ""
+}
+
+// mysqlType returns the narrowest MySQL type every value of the column fits.
+func (c *csvColumn) mysqlType() string {
+	switch {
+	case !c.seen:
+		return "longtext"
+	case !c.notBool:
+		return "tinyint(1)"
+	case !c.notInt && c.big:
+		return "bigint"
+	case !c.notInt:
+		return "int"
+	case !c.notDecimal && c.intDigits+c.scale <= 65 && c.scale <= 30:
+		return fmt.Sprintf("decimal(%d,%d)", max(c.intDigits+c.scale, 1), c.scale)
+	case !c.notFloat:
+		return "double"
+	case !c.notDate:
+		return "date"
+	case !c.notDatetime:
+		return "datetime"
+	case c.maxLen <= 255:
+		return "varchar(255)"
+	default:
+		return "longtext"
+	}
+}
+
+// ParseCSVFile infers one table per CSV file from a single file, a directory
+// or a zip archive. The first row of every file names the columns. comma
+// overrides the separator; 0 picks tab for .tsv files and comma otherwise.
+func ParseCSVFile(ctx context.Context, path string, comma rune) ([]ParsedTable, error) {
+	entries, closeFn, err := csvEntries(ctx, path, comma)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFn()
+
+	var tables []ParsedTable
+	for _, e := range entries {
+		table, err := parseCSVEntry(e)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", e.table, err)
+		}
+		if len(table.Fields) > 0 {
+			tables = append(tables, table)
+		}
+	}
+
+	var totalRows int64
+	for _, t := range tables {
+		totalRows += t.RowCount
+	}
+	log.Printf("Parsed %d tables, total %d rows (csv)", len(tables), totalRows)
+
+	return tables, nil
+}
+
+func parseCSVEntry(e csvEntry) (ParsedTable, error) {
+	table := ParsedTable{TableName: e.table}
+
+	r, err := e.open()
+	if err != nil {
+		return table, err
+	}
+	defer r.Close()
+
+	reader := newCSVReader(r, e.comma)
+	names, err := csvHeader(reader)
+	if err == io.EOF {
+		return table, nil
+	}
+	if err != nil {
+		return table, err
+	}
+
+	columns := make([]*csvColumn, len(names))
+	for i := range columns {
+		columns[i] = newCSVColumn()
+	}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return table, err
+		}
+		table.RowCount++
+		for i, c := range columns {
+			v := ""
+			if i < len(record) {
+				v = record[i]
+			}
+			c.observe(v, table.RowCount)
+		}
+	}
+
+	for i, name := range names {
+		table.Fields = append(table.Fields, Field{
+			Name:     name,
+			Type:     columns[i].mysqlType(),
+			Nullable: columns[i].nulls || !columns[i].seen,
+		})
+	}
+
+	if pk := csvPrimaryKey(table, columns); pk >= 0 {
+		table.Fields[pk].PrimaryKey = true
+		table.PrimaryKeys = []string{table.Fields[pk].Name}
+		log.Printf("%s: primary key candidate %s", table.TableName, table.Fields[pk].Name)
+	}
+	return table, nil
+}
+
+// csvPrimaryKey picks a column whose sampled values are present and unique,
+// preferring "id" and "<table>_id", then the first column if it holds
+// integers. It returns -1 when nothing qualifies.
+func csvPrimaryKey(table ParsedTable, columns []*csvColumn) int {
+	candidate := func(i int) bool {
+		c := columns[i]
+		return c.seen && !c.nulls && c.unique && (!c.notInt || c.maxLen <= 64)
+	}
+	for _, want := range []string{"id", table.TableName + "_id"} {
+		for i, f := range table.Fields {
+			if f.Name == want && candidate(i) {
+				return i
+			}
+		}
+	}
+	if len(columns) > 0 && candidate(0) && !columns[0].notInt {
+		return 0
+	}
+	return -1
+}
+
+// CSVRows streams the records of a CSV input, converting every value to the
+// Go type the dump readers produce for its inferred column type.
+type CSVRows struct {
+	Path   string
+	Comma  rune
+	Tables []ParsedTable
+}
+
+func (c *CSVRows) Each(ctx context.Context, fn func(Insert) error) error {
+	entries, closeFn, err := csvEntries(ctx, c.Path, c.Comma)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	tables := map[string]ParsedTable{}
+	for _, t := range c.Tables {
+		tables[t.TableName] = t
+	}
+	for _, e := range entries {
+		t, ok := tables[e.table]
+		if !ok {
+			continue
+		}
+		if err := eachCSVEntry(e, t, fn); err != nil {
+			return fmt.Errorf("%s: %v", e.table, err)
+		}
+	}
+	return nil
+}
+
+func eachCSVEntry(e csvEntry, t ParsedTable, fn func(Insert) error) error {
+	r, err := e.open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	reader := newCSVReader(r, e.comma)
+	if _, err := csvHeader(reader); err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+
+	cols := make([]string, len(t.Fields))
+	for i, f := range t.Fields {
+		cols[i] = f.Name
+	}
+	batch := Insert{Table: t.TableName, Columns: cols}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		row := make([]interface{}, len(cols))
+		for i, f := range t.Fields {
+			if i < len(record) {
+				row[i] = csvValue(record[i], f.Type)
+			}
+		}
+		batch.Rows = append(batch.Rows, row)
+		if len(batch.Rows) >= copyBatchRows {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			batch.Rows = nil
+		}
+	}
+	if len(batch.Rows) > 0 {
+		return fn(batch)
+	}
+	return nil
+}
+
+func csvValue(v, mysqlType string) interface{} {
+	if v == "" {
+		return nil
+	}
+	switch mysqlType {
+	case "tinyint(1)":
+		return copyValue(strings.ToLower(v), mysqlType)
+	case "date", "datetime":
+		if t, err := time.Parse(csvTimeLayout(v), v); err == nil {
+			if mysqlType == "date" {
+				return t.Format("2006-01-02")
+			}
+			return t.Format("2006-01-02 15:04:05.999999")
+		}
+		return v
+	}
+	return copyValue(v, mysqlType)
+}
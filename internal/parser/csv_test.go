@@ -0,0 +1,203 @@
+package parser
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestCSVColumnType(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   string
+	}{
+		{"empty", []string{"", ""}, "longtext"},
+		{"bool", []string{"true", "FALSE", ""}, "tinyint(1)"},
+		{"int", []string{"1", "-20", "2147483647"}, "int"},
+		{"bigint", []string{"1", "2147483648"}, "bigint"},
+		{"leading zero", []string{"0123", "45"}, "varchar(255)"},
+		{"decimal", []string{"1.5", "-12.25", "3"}, "decimal(4,2)"},
+		{"float", []string{"1e10", "2.5"}, "double"},
+		{"date", []string{"2024-02-29", "1999-12-31"}, "date"},
+		{"datetime", []string{"2024-02-29", "2024-02-29 13:45:01", "2024-02-29T13:45:01Z"}, "datetime"},
+		{"not a date", []string{"2024-02-30"}, "varchar(255)"},
+		{"text", []string{"abc", "1"}, "varchar(255)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCSVColumn()
+			for i, v := range tt.values {
+				c.observe(v, int64(i+1))
+			}
+			if got := c.mysqlType(); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVPrimaryKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		table   string
+		columns []string
+		rows    int
+		value   func(col string, row int) string
+		want    int
+	}{
+		{
+			name:    "id",
+			columns: []string{"name", "id"},
+			rows:    3,
+			value:   func(col string, row int) string { return col + strconv.Itoa(row) },
+			want:    1,
+		},
+		{
+			name:    "table id",
+			table:   "users",
+			columns: []string{"code", "users_id"},
+			rows:    3,
+			value:   func(col string, row int) string { return strconv.Itoa(row) },
+			want:    1,
+		},
+		{
+			name:    "first integer column",
+			columns: []string{"n", "name"},
+			rows:    3,
+			value:   func(col string, row int) string { return strconv.Itoa(row * 2) },
+			want:    0,
+		},
+		{
+			name:    "first column is text",
+			columns: []string{"name", "n"},
+			rows:    3,
+			value:   func(col string, row int) string { return col + strconv.Itoa(row) },
+			want:    -1,
+		},
+		{
+			name:    "duplicate",
+			columns: []string{"id"},
+			rows:    3,
+			value:   func(col string, row int) string { return strconv.Itoa(row % 2) },
+			want:    -1,
+		},
+		{
+			name:    "null",
+			columns: []string{"id"},
+			rows:    3,
+			value: func(col string, row int) string {
+				if row == 2 {
+					return ""
+				}
+				return strconv.Itoa(row)
+			},
+			want: -1,
+		},
+		{
+			name:    "increasing past the sample",
+			columns: []string{"id"},
+			rows:    csvSampleRows + 5,
+			value:   func(col string, row int) string { return strconv.Itoa(row * 3) },
+			want:    0,
+		},
+		{
+			name:    "unordered past the sample",
+			columns: []string{"id"},
+			rows:    csvSampleRows + 5,
+			value:   func(col string, row int) string { return strconv.Itoa(csvSampleRows*2 - row) },
+			want:    -1,
+		},
+		{
+			name:    "text past the sample",
+			columns: []string{"id"},
+			rows:    csvSampleRows + 5,
+			value:   func(col string, row int) string { return "u" + strconv.Itoa(row) },
+			want:    -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := ParsedTable{TableName: tt.table}
+			columns := make([]*csvColumn, len(tt.columns))
+			for i, name := range tt.columns {
+				table.Fields = append(table.Fields, Field{Name: name})
+				columns[i] = newCSVColumn()
+			}
+			for row := 1; row <= tt.rows; row++ {
+				for i, name := range tt.columns {
+					columns[i].observe(tt.value(name, row), int64(row))
+				}
+			}
+			if got := csvPrimaryKey(table, columns); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCSVZipSameBaseName reads an archive holding two files of the same
+// name in different folders: each must become its own table, in both the
+// schema and the data pass.
+func TestCSVZipSameBaseName(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, m := range []struct{ name, body string }{
+		{"a/users.csv", "id,name\n1,ada\n2,linus\n"},
+		{"b/users.csv", "id,email\n7,grace@example.com\n"},
+	} {
+		w, err := zw.Create(m.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(m.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	tables, err := ParseCSVFile(ctx, path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, table := range tables {
+		names = append(names, table.TableName)
+	}
+	if want := []string{"users", "users_2"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("got tables %v, want %v", names, want)
+	}
+
+	got := map[string][][]interface{}{}
+	rows := &CSVRows{Path: path, Tables: tables}
+	err = rows.Each(ctx, func(ins Insert) error {
+		got[ins.Table] = append(got[ins.Table], ins.Rows...)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][][]interface{}{
+		"users":   {{int64(1), "ada"}, {int64(2), "linus"}},
+		"users_2": {{int64(7), "grace@example.com"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got rows %v, want %v", got, want)
+	}
+}
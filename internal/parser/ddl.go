@@ -0,0 +1,319 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// unquoteIdent returns the last part of a possibly schema-qualified
+// identifier, removing "double", `backtick` or [bracket] quoting.
+func unquoteIdent(name string) string {
+	name = strings.TrimSpace(name)
+	var parts []string
+	var sb strings.Builder
+	var quote byte
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case quote != 0 && c == quote && quote != ']' && i+1 < len(name) && name[i+1] == quote:
+			sb.WriteByte(c)
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			sb.WriteByte(c)
+		case c == '"' || c == '`':
+			quote = c
+		case c == '[':
+			quote = ']'
+		case c == '.':
+			parts = append(parts, sb.String())
+			sb.Reset()
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	parts = append(parts, sb.String())
+	return parts[len(parts)-1]
+}
+
+func splitIdents(list string) []string {
+	var names []string
+	for _, part := range splitTopLevel(list) {
+		if part = strings.TrimSpace(part); part != "" {
+			names = append(names, unquoteIdent(part))
+		}
+	}
+	return names
+}
+
+// splitTopLevel splits on commas that are not nested in parentheses or
+// quotes.
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth := 0
+	start := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// closingParen returns the index of the parenthesis closing the one at open,
+// or -1.
+func closingParen(s string, open int) int {
+	depth := 0
+	var quote byte
+	for i := open; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parseCreateDefinitions reads a standard-SQL CREATE TABLE statement, as
+// written by pg_dump and sqlite3. mapType spells a column type the way a
+// MySQL dump would and reports types that imply auto increment.
+func parseCreateDefinitions(stmt string, mapType func(string) (string, bool)) (ParsedTable, bool) {
+	var table ParsedTable
+
+	open := strings.Index(stmt, "(")
+	if open < 0 {
+		return table, false
+	}
+	closeIdx := closingParen(stmt, open)
+	if closeIdx < 0 {
+		return table, false
+	}
+
+	reHead := regexp.MustCompile(`(?is)^CREATE\s+(?:\w+\s+)*?TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?(.+?)\s*$`)
+	head := reHead.FindStringSubmatch(stmt[:open])
+	if len(head) < 2 {
+		return table, false
+	}
+	table.TableName = unquoteIdent(head[1])
+
+	reColumnEnd := regexp.MustCompile(`(?i)\s+(NOT\s+NULL|NULL|DEFAULT|CONSTRAINT|PRIMARY\s+KEY|UNIQUE|REFERENCES|CHECK|COLLATE|GENERATED|AS)\b`)
+	reRefs := regexp.MustCompile(`(?i)(?:\bCONSTRAINT\s+(\S+)\s+)?\b(REFERENCES)\b`)
+	reTableConstraint := regexp.MustCompile(`(?i)^(?:CONSTRAINT\s+(\S+)\s+)?(PRIMARY\s+KEY|UNIQUE|FOREIGN\s+KEY)\s*\(([^)]*)\)`)
+
+	var indexes []Index
+	var foreignKeys []ForeignKey
+
+	for _, item := range splitTopLevel(stmt[open+1 : closeIdx]) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		if m := reTableConstraint.FindStringSubmatch(item); len(m) == 4 {
+			cols := splitIdents(m[3])
+			kind := strings.ToUpper(strings.Join(strings.Fields(m[2]), " "))
+			switch kind {
+			case "PRIMARY KEY":
+				for _, c := range cols {
+					table.PrimaryKeys = appendIfMissing(table.PrimaryKeys, c)
+				}
+			case "UNIQUE":
+				ix := Index{Unique: true}
+				if m[1] != "" {
+					ix.Name = unquoteIdent(m[1])
+				}
+				for _, c := range cols {
+					ix.Columns = append(ix.Columns, IndexColumn{Name: c})
+				}
+				indexes = append(indexes, ix)
+			case "FOREIGN KEY":
+				if fk, ok := parseForeignKey(item); ok {
+					foreignKeys = append(foreignKeys, fk)
+				}
+			}
+			continue
+		}
+		if strings.HasPrefix(strings.ToUpper(item), "CONSTRAINT") || strings.HasPrefix(strings.ToUpper(item), "CHECK") {
+			continue
+		}
+
+		name, rest := splitColumn(item)
+		if name == "" {
+			continue
+		}
+		colType := rest
+		extra := ""
+		if loc := reColumnEnd.FindStringIndex(" " + rest); loc != nil {
+			colType = strings.TrimSpace((" " + rest)[:loc[0]])
+			extra = (" " + rest)[loc[0]:]
+		}
+		upperExtra := strings.ToUpper(extra)
+
+		mysqlType, serial := mapType(colType)
+		field := Field{
+			Name:     name,
+			Type:     mysqlType,
+			Nullable: !strings.Contains(upperExtra, "NOT NULL") && !strings.Contains(upperExtra, "PRIMARY KEY"),
+			AutoIncrement: serial || strings.Contains(upperExtra, "AUTOINCREMENT") ||
+				(strings.Contains(upperExtra, "GENERATED") && strings.Contains(upperExtra, "IDENTITY")),
+		}
+		def, nextval := sqlDefault(extra, mysqlType)
+		field.Default = def
+		field.AutoIncrement = field.AutoIncrement || nextval
+
+		if strings.Contains(upperExtra, "PRIMARY KEY") {
+			table.PrimaryKeys = appendIfMissing(table.PrimaryKeys, name)
+		}
+		if strings.Contains(upperExtra, "UNIQUE") {
+			indexes = append(indexes, Index{Columns: []IndexColumn{{Name: name}}, Unique: true})
+		}
+		if loc := reRefs.FindStringSubmatchIndex(extra); loc != nil {
+			if fk, ok := parseReferences(extra[loc[4]:]); ok {
+				fk.Columns = []string{name}
+				if loc[2] >= 0 {
+					fk.Name = unquoteIdent(extra[loc[2]:loc[3]])
+				}
+				foreignKeys = append(foreignKeys, fk)
+			}
+		}
+		table.Fields = append(table.Fields, field)
+	}
+
+	for i := range table.Fields {
+		for _, pk := range table.PrimaryKeys {
+			if table.Fields[i].Name == pk {
+				table.Fields[i].PrimaryKey = true
+				table.Fields[i].Nullable = false
+			}
+		}
+	}
+	for _, ix := range indexes {
+		table.addIndex(ix)
+	}
+	for _, fk := range foreignKeys {
+		table.addForeignKey(fk)
+	}
+	return table, table.TableName != ""
+}
+
+// splitColumn separates the column name of a definition from the rest.
+func splitColumn(item string) (string, string) {
+	if item[0] == '"' || item[0] == '`' || item[0] == '[' {
+		closing := item[0]
+		if closing == '[' {
+			closing = ']'
+		}
+		for i := 1; i < len(item); i++ {
+			if item[i] != closing {
+				continue
+			}
+			if closing != ']' && i+1 < len(item) && item[i+1] == closing {
+				i++
+				continue
+			}
+			return unquoteIdent(item[:i+1]), strings.TrimSpace(item[i+1:])
+		}
+		return "", ""
+	}
+	parts := strings.SplitN(item, " ", 2)
+	if len(parts) < 2 {
+		// SQLite allows columns without a type.
+		return parts[0], ""
+	}
+	return parts[0], strings.TrimSpace(parts[1])
+}
+
+// sqlDefault extracts a column default in the form the MySQL reader
+// produces. The second result reports a nextval() default, i.e. a serial.
+func sqlDefault(extra, mysqlType string) (string, bool) {
+	reDefault := regexp.MustCompile(`(?is)\bDEFAULT\s+('(?:[^']|'')*'|[^\s,]+(?:\([^)]*\))?)`)
+	m := reDefault.FindStringSubmatch(extra)
+	if len(m) < 2 {
+		return "", false
+	}
+
+	def := m[1]
+	lower := strings.ToLower(def)
+	switch {
+	case strings.HasPrefix(lower, "nextval("):
+		return "", true
+	case strings.HasPrefix(def, "'"):
+		end := strings.LastIndex(def, "'")
+		return strings.ReplaceAll(def[1:end], "''", "'"), false
+	case strings.HasPrefix(lower, "null"):
+		return "NULL", false
+	case lower == "now()" || strings.HasPrefix(lower, "current_timestamp") || strings.HasPrefix(lower, "localtimestamp"):
+		return "CURRENT_TIMESTAMP", false
+	case lower == "true" || lower == "false":
+		if mysqlType == "tinyint(1)" {
+			if lower == "true" {
+				return "1", false
+			}
+			return "0", false
+		}
+		return lower, false
+	}
+
+	def = strings.Trim(strings.SplitN(def, "::", 2)[0], "()")
+	if _, err := strconv.ParseFloat(def, 64); err == nil {
+		return def, false
+	}
+	// Any other expression has no portable equivalent.
+	return "", false
+}
+
+func parseCreateIndex(stmt string, find func(string) *ParsedTable) {
+	reIndex := regexp.MustCompile(`(?is)^CREATE\s+(UNIQUE\s+)?INDEX\s+(?:CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?(\S+)\s+ON\s+(?:ONLY\s+)?(\S+?)(?:\s+USING\s+(\w+))?\s*\(`)
+	m := reIndex.FindStringSubmatch(stmt)
+	if len(m) < 5 {
+		return
+	}
+	table := find(unquoteIdent(m[3]))
+	open := len(m[0]) - 1
+	closeIdx := closingParen(stmt, open)
+	if table == nil || closeIdx < 0 {
+		return
+	}
+	cols, kind, ok := parseIndexColumns(stmt[open+1:closeIdx], m[4])
+	if !ok {
+		return
+	}
+
+	// A partial unique index only holds for some rows; it is kept as a
+	// plain index rather than made stricter than the source.
+	partial := regexp.MustCompile(`(?i)^\s*WHERE\b`).MatchString(stmt[closeIdx+1:])
+	table.addIndex(Index{
+		Name:    unquoteIdent(m[2]),
+		Columns: cols,
+		Unique:  strings.TrimSpace(m[1]) != "" && !partial && kind == "",
+		Type:    kind,
+	})
+}
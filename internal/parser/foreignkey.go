@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ForeignKey is a foreign key of a table over one or more columns, paired
+// in order with the referenced columns.
+type ForeignKey struct {
+	Name              string   `json:"name,omitempty"`
+	Columns           []string `json:"columns"`
+	ReferencedTable   string   `json:"referenced_table"`
+	ReferencedColumns []string `json:"referenced_columns"`
+	OnDelete          string   `json:"on_delete,omitempty"` // CASCADE, SET NULL, SET DEFAULT, RESTRICT or NO ACTION
+	OnUpdate          string   `json:"on_update,omitempty"`
+	Deferrable        bool     `json:"deferrable,omitempty"`
+	InitiallyDeferred bool     `json:"initially_deferred,omitempty"`
+}
+
+// addForeignKey adds fk to the table unless a key over the same columns is
+// already there. A single-column key is also set on its field, where the
+// MongoDB embedding looks for it.
+func (t *ParsedTable) addForeignKey(fk ForeignKey) {
+	if len(fk.Columns) == 0 || len(fk.Columns) != len(fk.ReferencedColumns) {
+		return
+	}
+	key := strings.ToLower(strings.Join(fk.Columns, ","))
+	for _, other := range t.ForeignKeys {
+		if strings.ToLower(strings.Join(other.Columns, ",")) == key {
+			return
+		}
+	}
+	t.ForeignKeys = append(t.ForeignKeys, fk)
+
+	if len(fk.Columns) != 1 {
+		return
+	}
+	for i := range t.Fields {
+		if strings.EqualFold(t.Fields[i].Name, fk.Columns[0]) {
+			t.Fields[i].ForeignKey = &ForeignKeyMeta{ReferencedTable: fk.ReferencedTable, ReferencedField: fk.ReferencedColumns[0]}
+		}
+	}
+}
+
+const identPattern = "(\"(?:[^\"]|\"\")*\"|`(?:[^`]|``)*`|\\[[^\\]]*\\]|[^\\s(]+)"
+
+// parseForeignKey reads a table constraint such as
+// "CONSTRAINT `fk` FOREIGN KEY (`a`,`b`) REFERENCES `t` (`x`,`y`) ON DELETE CASCADE",
+// as MySQL, PostgreSQL and SQLite write it.
+func parseForeignKey(def string) (ForeignKey, bool) {
+	reHead := regexp.MustCompile(`(?is)^(?:CONSTRAINT\s+` + identPattern + `\s+)?FOREIGN\s+KEY\s*(?:` + identPattern + `\s*)?\(([^)]*)\)\s*`)
+	m := reHead.FindStringSubmatch(strings.TrimSpace(def))
+	if len(m) < 4 {
+		return ForeignKey{}, false
+	}
+	fk, ok := parseReferences(strings.TrimSpace(def)[len(m[0]):])
+	if !ok {
+		return fk, false
+	}
+	if m[1] != "" {
+		fk.Name = unquoteIdent(m[1])
+	}
+	fk.Columns = splitIdents(m[3])
+	return fk, len(fk.Columns) == len(fk.ReferencedColumns)
+}
+
+// parseReferences reads a REFERENCES clause with its referential actions
+// and deferrability. A clause without a column list, which SQLite allows
+// for the primary key, is not supported.
+func parseReferences(clause string) (ForeignKey, bool) {
+	var fk ForeignKey
+	reRefs := regexp.MustCompile(`(?is)^REFERENCES\s+` + identPattern + `\s*\(([^)]*)\)(.*)$`)
+	m := reRefs.FindStringSubmatch(strings.TrimSpace(clause))
+	if len(m) < 4 {
+		return fk, false
+	}
+	fk.ReferencedTable = unquoteIdent(m[1])
+	fk.ReferencedColumns = splitIdents(m[2])
+
+	rest := strings.Join(strings.Fields(strings.ToUpper(m[3])), " ")
+	reAction := regexp.MustCompile(`ON (DELETE|UPDATE) (CASCADE|SET NULL|SET DEFAULT|RESTRICT|NO ACTION)`)
+	for _, a := range reAction.FindAllStringSubmatch(rest, -1) {
+		if a[1] == "DELETE" {
+			fk.OnDelete = a[2]
+		} else {
+			fk.OnUpdate = a[2]
+		}
+	}
+	fk.Deferrable = strings.Contains(" "+rest, " DEFERRABLE") && !strings.Contains(rest, "NOT DEFERRABLE")
+	fk.InitiallyDeferred = fk.Deferrable && strings.Contains(rest, "INITIALLY DEFERRED")
+	return fk, len(fk.ReferencedColumns) > 0
+}
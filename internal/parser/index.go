@@ -0,0 +1,146 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Kinds of Index besides plain and unique B-tree keys.
+const (
+	IndexFulltext = "fulltext"
+	IndexSpatial  = "spatial"
+)
+
+// Index is a key of a table over one or more columns, in key order.
+type Index struct {
+	Name    string        `json:"name,omitempty"`
+	Columns []IndexColumn `json:"columns"`
+	Unique  bool          `json:"unique,omitempty"`
+	Type    string        `json:"type,omitempty"` // "" for B-tree, IndexFulltext or IndexSpatial
+}
+
+// IndexColumn is one column of an index. Length is the prefix length of a
+// MySQL key on part of a string column.
+type IndexColumn struct {
+	Name   string `json:"name"`
+	Length int    `json:"length,omitempty"`
+}
+
+// ColumnNames returns the columns of the index in key order.
+func (ix Index) ColumnNames() []string {
+	names := make([]string, len(ix.Columns))
+	for i, c := range ix.Columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// addIndex adds ix to the table unless an equal key is already there. The
+// fields are flagged the way single-column keys always were: Unique for a
+// one-column unique key, Index for the columns of any other key.
+func (t *ParsedTable) addIndex(ix Index) {
+	if len(ix.Columns) == 0 {
+		return
+	}
+	key := strings.ToLower(strings.Join(ix.ColumnNames(), ","))
+	for _, other := range t.Indexes {
+		if other.Unique == ix.Unique && other.Type == ix.Type &&
+			strings.ToLower(strings.Join(other.ColumnNames(), ",")) == key {
+			return
+		}
+	}
+	t.Indexes = append(t.Indexes, ix)
+
+	oneUnique := ix.Unique && len(ix.Columns) == 1
+	if oneUnique {
+		t.UniqueKeys = appendIfMissing(t.UniqueKeys, ix.Columns[0].Name)
+	}
+	for i := range t.Fields {
+		for _, c := range ix.Columns {
+			if !strings.EqualFold(t.Fields[i].Name, c.Name) {
+				continue
+			}
+			if oneUnique {
+				t.Fields[i].Unique = true
+			} else {
+				t.Fields[i].Index = true
+			}
+		}
+	}
+}
+
+// parseMySQLIndex reads an index definition of a MySQL CREATE TABLE, or what
+// follows ADD in an ALTER TABLE, such as "UNIQUE KEY `uq` (`a`,`b`(10))".
+// Keys over expressions are not supported and reported as not found.
+func parseMySQLIndex(def string) (Index, bool) {
+	var ix Index
+	reHead := regexp.MustCompile("(?is)^(?:CONSTRAINT(?:\\s+`(?:[^`]|``)*`|\\s+\\w+)?\\s+)?" +
+		"(?:(UNIQUE|FULLTEXT|SPATIAL)(?:\\s+(?:KEY|INDEX))?|KEY|INDEX)" +
+		"\\s*(`(?:[^`]|``)*`|\\w+)?\\s*(?:USING\\s+\\w+\\s*)?\\(")
+	m := reHead.FindStringSubmatch(def)
+	if len(m) < 3 {
+		return ix, false
+	}
+	switch strings.ToUpper(m[1]) {
+	case "UNIQUE":
+		ix.Unique = true
+	case "FULLTEXT":
+		ix.Type = IndexFulltext
+	case "SPATIAL":
+		ix.Type = IndexSpatial
+	}
+	if m[2] != "" {
+		ix.Name = unquoteIdent(m[2])
+	}
+
+	open := len(m[0]) - 1
+	closeIdx := closingParen(def, open)
+	if closeIdx < 0 {
+		return ix, false
+	}
+	rePart := regexp.MustCompile("(?is)^(`(?:[^`]|``)*`|\"[^\"]*\"|\\w+)\\s*(?:\\(\\s*(\\d+)\\s*\\))?(?:\\s+(?:ASC|DESC))?$")
+	for _, part := range splitTopLevel(def[open+1 : closeIdx]) {
+		p := rePart.FindStringSubmatch(strings.TrimSpace(part))
+		if len(p) < 3 {
+			return ix, false
+		}
+		col := IndexColumn{Name: unquoteIdent(p[1])}
+		col.Length, _ = strconv.Atoi(p[2])
+		ix.Columns = append(ix.Columns, col)
+	}
+	return ix, len(ix.Columns) > 0
+}
+
+// parseIndexColumns reads the key of a standard-SQL CREATE INDEX. Plain
+// columns may carry a collation, operator class or sort order; a GIN key
+// over to_tsvector() is read as a full-text index of the columns in it.
+func parseIndexColumns(body, method string) ([]IndexColumn, string, bool) {
+	var cols []IndexColumn
+	if strings.EqualFold(method, "gin") && strings.Contains(strings.ToLower(body), "to_tsvector") {
+		reCoalesce := regexp.MustCompile(`(?i)coalesce\(\s*\(*\s*("[^"]+"|\w+)`)
+		reVector := regexp.MustCompile(`(?i)to_tsvector\(\s*(?:'[^']*'(?:::\w+)?\s*,\s*)?\(*\s*("[^"]+"|\w+)\s*\)`)
+		matches := reCoalesce.FindAllStringSubmatch(body, -1)
+		if len(matches) == 0 {
+			matches = reVector.FindAllStringSubmatch(body, -1)
+		}
+		for _, m := range matches {
+			cols = append(cols, IndexColumn{Name: unquoteIdent(m[1])})
+		}
+		return cols, IndexFulltext, len(cols) > 0
+	}
+
+	kind := ""
+	if strings.EqualFold(method, "gist") || strings.EqualFold(method, "spgist") {
+		kind = IndexSpatial
+	}
+	rePart := regexp.MustCompile(`^("(?:[^"]|"")*"|` + "`(?:[^`]|``)*`" + `|\[[^\]]*\]|\w+)(?:\s+.*)?$`)
+	for _, part := range splitTopLevel(body) {
+		p := rePart.FindStringSubmatch(strings.TrimSpace(part))
+		if len(p) < 2 {
+			return nil, "", false
+		}
+		cols = append(cols, IndexColumn{Name: unquoteIdent(p[1])})
+	}
+	return cols, kind, len(cols) > 0
+}
@@ -0,0 +1,146 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Input formats accepted by ParseFile.
+const (
+	FormatMySQL    = "mysql"
+	FormatPostgres = "postgres"
+	FormatSQLite   = "sqlite"
+	FormatSQLiteDB = "sqlite-db"
+	FormatCSV      = "csv"
+	FormatTSV      = "tsv"
+	FormatJSON     = "json"
+)
+
+// DetectFormat sniffs the head of a file to tell which reader handles it,
+// looking through gzip, zstd, bzip2, xz and zip compression. CSV files carry
+// no signature, so they are recognized by extension; a directory, or a zip
+// archive holding CSV files, is read as a set of CSV files.
+func DetectFormat(filePath string) (string, error) {
+	if info, err := os.Stat(filePath); err == nil && info.IsDir() {
+		return FormatCSV, nil
+	}
+	switch inputExt(filePath) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".tsv", ".tab":
+		return FormatTSV, nil
+	case ".json", ".ndjson", ".jsonl":
+		return FormatJSON, nil
+	}
+	if fileCompression(filePath) == compressionZip && zipHasCSV(filePath) {
+		return FormatCSV, nil
+	}
+
+	file, err := openInput(context.Background(), filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	head := make([]byte, 64*1024)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, []byte("SQLite format 3\x00")):
+		return FormatSQLiteDB, nil
+	case bytes.HasPrefix(bytes.TrimLeft(head, " \t\r\n"), []byte("{")),
+		bytes.HasPrefix(bytes.TrimLeft(head, " \t\r\n"), []byte("[")):
+		return FormatJSON, nil
+	case bytes.Contains(head, []byte("PostgreSQL database dump")),
+		bytes.Contains(head, []byte("SET standard_conforming_strings")),
+		bytes.Contains(head, []byte("pg_catalog.set_config")):
+		return FormatPostgres, nil
+	case bytes.HasPrefix(head, []byte("PRAGMA foreign_keys=OFF;")),
+		bytes.HasPrefix(head, []byte("BEGIN TRANSACTION;")):
+		// The first lines sqlite3 ".dump" writes.
+		return FormatSQLite, nil
+	default:
+		return FormatMySQL, nil
+	}
+}
+
+// ParseFile reads the schema of an input file and returns it together with a
+// RowSource replaying its data. An empty format is detected from the content.
+// Cancelling ctx stops the schema pass. A Progress attached to ctx follows
+// the schema pass, and the RowSource reports its rows to the Progress of the
+// context it is replayed with. The caller runs cleanup once it is done with
+// the RowSource, to remove the temporary files the input needed; cleanup is
+// never nil.
+func ParseFile(ctx context.Context, filePath, format string) ([]ParsedTable, RowSource, func(), error) {
+	ProgressFrom(ctx).Pass()
+	cleanup := func() {}
+	tables, rows, err := parseInput(ctx, filePath, format, &cleanup)
+	if err != nil {
+		cleanup()
+		return nil, nil, func() {}, err
+	}
+	if rows != nil {
+		rows = progressRows{rows}
+	}
+	for i := range tables {
+		for j := range tables[i].Fields {
+			f := &tables[i].Fields[j]
+			f.TypeInfo = f.ColumnType()
+		}
+	}
+	return tables, rows, cleanup, nil
+}
+
+func parseInput(ctx context.Context, filePath, format string, cleanup *func()) ([]ParsedTable, RowSource, error) {
+	if format == "" {
+		detected, err := DetectFormat(filePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		format = detected
+	}
+
+	switch format {
+	case FormatMySQL, "mariadb":
+		tables, err := ParseSQLFile(ctx, filePath)
+		return tables, &DumpRows{Path: filePath, Tables: tables}, err
+	case FormatPostgres, "postgresql", "pg_dump":
+		tables, err := ParsePostgresFile(ctx, filePath)
+		return tables, &PostgresDumpRows{Path: filePath, Tables: tables}, err
+	case FormatSQLite, "sqlite3":
+		// "sqlite" names both a dump and a database file.
+		if detected, err := DetectFormat(filePath); err == nil && detected == FormatSQLiteDB {
+			return parseInput(ctx, filePath, FormatSQLiteDB, cleanup)
+		}
+		tables, err := ParseSQLiteDumpFile(ctx, filePath)
+		return tables, &SQLiteDumpRows{Path: filePath, Tables: tables}, err
+	case FormatSQLiteDB:
+		// SQLite needs random access, so compressed files are unpacked first.
+		dbPath, remove, err := decompressedCopy(ctx, filePath, ".db")
+		if err != nil {
+			return nil, nil, err
+		}
+		*cleanup = remove
+		tables, err := ParseSQLiteDBFile(ctx, dbPath)
+		return tables, &SQLiteFileRows{Path: dbPath, Tables: tables}, err
+	case FormatCSV, FormatTSV:
+		comma := rune(0)
+		if format == FormatTSV {
+			comma = '\t'
+		}
+		tables, err := ParseCSVFile(ctx, filePath, comma)
+		return tables, &CSVRows{Path: filePath, Comma: comma, Tables: tables}, err
+	case FormatJSON, "ndjson", "jsonl":
+		tables, err := ParseJSONFile(ctx, filePath)
+		return tables, &JSONRows{Path: filePath, Tables: tables}, err
+	default:
+		return nil, nil, fmt.Errorf("unsupported input format: %s", format)
+	}
+}
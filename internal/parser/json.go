@@ -0,0 +1,460 @@
+package parser
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+)
+
+// Columns generated for documents: every table gets a row_id key and child
+// tables reference their parent through <parent>_row_id.
+const jsonKeyColumn = "row_id"
+
+// Kinds of JSON values seen in a column.
+const (
+	jsonBool = 1 << iota
+	jsonNumber
+	jsonString
+	jsonRaw
+)
+
+// jsonColumn extends the CSV type inference with the JSON kind of values, so
+// a string "12" is not taken for a number.
+type jsonColumn struct {
+	*csvColumn
+	kinds    int
+	observed int64
+}
+
+func (c *jsonColumn) observe(v interface{}, row int64) {
+	c.observed++
+	switch v.(type) {
+	case nil:
+		c.csvColumn.observe("", row)
+		return
+	case bool:
+		c.kinds |= jsonBool
+	case json.Number:
+		c.kinds |= jsonNumber
+	case string:
+		c.kinds |= jsonString
+	default:
+		c.kinds |= jsonRaw
+		c.seen = true
+		return
+	}
+	c.csvColumn.observe(jsonText(v), row)
+}
+
+func (c *jsonColumn) mysqlType() string {
+	switch c.kinds {
+	case 0:
+		return "longtext"
+	case jsonBool:
+		return "tinyint(1)"
+	case jsonNumber:
+		return c.csvColumn.mysqlType()
+	case jsonString:
+		switch t := c.csvColumn.mysqlType(); t {
+		case "date", "datetime", "longtext", "varchar(255)":
+			return t
+		}
+		if c.maxLen <= 255 {
+			return "varchar(255)"
+		}
+		return "longtext"
+	}
+	if c.kinds&jsonRaw != 0 {
+		return "json"
+	}
+	return "longtext"
+}
+
+// jsonTable is one relational table derived from documents: the root
+// documents or the array-of-objects members at one path.
+type jsonTable struct {
+	name    string
+	parent  string
+	columns []string
+	types   map[string]*jsonColumn
+	rows    int64
+}
+
+func (t *jsonTable) column(name string) *jsonColumn {
+	c, ok := t.types[name]
+	if !ok {
+		c = &jsonColumn{csvColumn: newCSVColumn()}
+		t.types[name] = c
+		t.columns = append(t.columns, name)
+	}
+	return c
+}
+
+// jsonSchema maps documents onto tables. The same walk drives schema
+// inference and the data pass, so generated row ids line up.
+type jsonSchema struct {
+	tables []*jsonTable
+	index  map[string]*jsonTable
+}
+
+func newJSONSchema() *jsonSchema {
+	return &jsonSchema{index: map[string]*jsonTable{}}
+}
+
+func (s *jsonSchema) table(name, parent string) *jsonTable {
+	t, ok := s.index[name]
+	if !ok {
+		t = &jsonTable{name: name, parent: parent, types: map[string]*jsonColumn{}}
+		s.index[name] = t
+		s.tables = append(s.tables, t)
+	}
+	return t
+}
+
+func parentKeyColumn(parent string) string {
+	return parent + "_" + jsonKeyColumn
+}
+
+type jsonChild struct {
+	table string
+	docs  []interface{}
+}
+
+// walk flattens one document into a row of table name and hands it to emit
+// before descending into its child arrays.
+func (s *jsonSchema) walk(name, parent string, parentID int64, doc map[string]interface{}, emit func(*jsonTable, map[string]interface{})) {
+	t := s.table(name, parent)
+	t.rows++
+
+	row := map[string]interface{}{jsonKeyColumn: t.rows}
+	if parent != "" {
+		row[parentKeyColumn(parent)] = parentID
+	}
+	var children []jsonChild
+	s.flatten(t, "", doc, row, &children)
+	emit(t, row)
+
+	for _, child := range children {
+		for _, d := range child.docs {
+			if obj, ok := d.(map[string]interface{}); ok {
+				s.walk(child.table, t.name, t.rows, obj, emit)
+			}
+		}
+	}
+}
+
+func (s *jsonSchema) flatten(t *jsonTable, prefix string, obj map[string]interface{}, row map[string]interface{}, children *[]jsonChild) {
+	// Decoded objects lose their key order; sorting keeps columns and child
+	// tables in the same order on every pass.
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		v := obj[key]
+		name := columnIdent(key)
+		if name == "" {
+			continue
+		}
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+		if name == jsonKeyColumn || (t.parent != "" && name == parentKeyColumn(t.parent)) {
+			name += "_value"
+		}
+
+		switch val := v.(type) {
+		case nil:
+			// A null is the same as a missing key: the column stays NULL.
+		case map[string]interface{}:
+			s.flatten(t, name, val, row, children)
+		case []interface{}:
+			switch {
+			case isObjectArray(val):
+				*children = append(*children, jsonChild{table: t.name + "_" + name, docs: val})
+			case !isEmptyArray(val):
+				row[name] = val
+			}
+		default:
+			row[name] = val
+		}
+	}
+}
+
+// isObjectArray reports arrays that hold only objects (and nulls); those
+// become child tables, any other array is kept as a JSON column.
+func isObjectArray(arr []interface{}) bool {
+	objects := 0
+	for _, v := range arr {
+		switch v.(type) {
+		case map[string]interface{}:
+			objects++
+		case nil:
+		default:
+			return false
+		}
+	}
+	return objects > 0
+}
+
+func isEmptyArray(arr []interface{}) bool {
+	for _, v := range arr {
+		if v != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func jsonText(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case bool:
+		if val {
+			return "true"
+		}
+		return "false"
+	default:
+		b, _ := json.Marshal(val)
+		return string(b)
+	}
+}
+
+// eachJSONDocument decodes a JSON array of objects, a single object or
+// newline-delimited JSON one document at a time.
+func eachJSONDocument(ctx context.Context, path string, fn func(map[string]interface{}) error) error {
+	file, err := openInput(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r := bufio.NewReaderSize(file, 1<<20)
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	first, err := firstNonSpace(r)
+	if err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+
+	if first == '[' {
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+		for dec.More() {
+			if err := decodeJSONDocument(dec, fn); err != nil {
+				return err
+			}
+		}
+		_, err := dec.Token()
+		return err
+	}
+
+	for {
+		err := decodeJSONDocument(dec, fn)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func firstNonSpace(r *bufio.Reader) (byte, error) {
+	for n := 1; ; n++ {
+		b, err := r.Peek(n)
+		if err != nil {
+			return 0, err
+		}
+		switch c := b[n-1]; c {
+		case ' ', '\t', '\r', '\n':
+		default:
+			return c, nil
+		}
+	}
+}
+
+func decodeJSONDocument(dec *json.Decoder, fn func(map[string]interface{}) error) error {
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		if err == io.EOF {
+			return err
+		}
+		return fmt.Errorf("json decode error at offset %d: %v", dec.InputOffset(), err)
+	}
+	doc, ok := v.(map[string]interface{})
+	if !ok {
+		log.Printf("Skipping non-object JSON value at offset %d", dec.InputOffset())
+		return nil
+	}
+	return fn(doc)
+}
+
+func jsonRootTable(path string) string {
+	name := columnIdent(inputBase(path))
+	if name == "" {
+		return "documents"
+	}
+	return name
+}
+
+// ParseJSONFile infers a relational schema from a JSON or NDJSON file. The
+// documents form one table named after the file; nested objects are
+// flattened into prefixed columns and arrays of objects become child tables
+// linked to their parent row.
+func ParseJSONFile(ctx context.Context, path string) ([]ParsedTable, error) {
+	schema := newJSONSchema()
+	root := jsonRootTable(path)
+
+	err := eachJSONDocument(ctx, path, func(doc map[string]interface{}) error {
+		schema.walk(root, "", 0, doc, func(t *jsonTable, row map[string]interface{}) {
+			names := make([]string, 0, len(row))
+			for name := range row {
+				if name != jsonKeyColumn && (t.parent == "" || name != parentKeyColumn(t.parent)) {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				t.column(name).observe(row[name], t.rows)
+			}
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []ParsedTable
+	var totalRows int64
+	for _, t := range schema.tables {
+		table := ParsedTable{
+			TableName:   t.name,
+			PrimaryKeys: []string{jsonKeyColumn},
+			RowCount:    t.rows,
+		}
+		table.Fields = append(table.Fields, Field{Name: jsonKeyColumn, Type: "bigint", PrimaryKey: true})
+		if t.parent != "" {
+			table.Fields = append(table.Fields, Field{
+				Name:       parentKeyColumn(t.parent),
+				Type:       "bigint",
+				Index:      true,
+				ForeignKey: &ForeignKeyMeta{ReferencedTable: t.parent, ReferencedField: jsonKeyColumn},
+			})
+		}
+		for _, name := range t.columns {
+			c := t.types[name]
+			table.Fields = append(table.Fields, Field{
+				Name: name,
+				Type: c.mysqlType(),
+				// A key missing from some documents is a NULL as well.
+				Nullable: c.nulls || !c.seen || c.observed < t.rows,
+			})
+		}
+		tables = append(tables, table)
+		totalRows += t.rows
+	}
+	log.Printf("Parsed %d tables, total %d rows (json)", len(tables), totalRows)
+
+	return tables, nil
+}
+
+// JSONRows replays the documents of a JSON input as rows of the tables
+// ParseJSONFile derived from it.
+type JSONRows struct {
+	Path   string
+	Tables []ParsedTable
+}
+
+func (j *JSONRows) Each(ctx context.Context, fn func(Insert) error) error {
+	columns := map[string][]string{}
+	types := map[string][]string{}
+	var order []string
+	for _, t := range j.Tables {
+		for _, f := range t.Fields {
+			columns[t.TableName] = append(columns[t.TableName], f.Name)
+			types[t.TableName] = append(types[t.TableName], f.Type)
+		}
+		order = append(order, t.TableName)
+	}
+
+	batches := map[string]*Insert{}
+	// Every pending batch goes out together, parents first: child rows
+	// reference parent rows that may still be waiting in their batch.
+	flushAll := func() error {
+		for _, name := range order {
+			b, ok := batches[name]
+			if !ok || len(b.Rows) == 0 {
+				continue
+			}
+			err := fn(*b)
+			b.Rows = nil
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	schema := newJSONSchema()
+	root := jsonRootTable(j.Path)
+	err := eachJSONDocument(ctx, j.Path, func(doc map[string]interface{}) error {
+		var emitErr error
+		schema.walk(root, "", 0, doc, func(t *jsonTable, values map[string]interface{}) {
+			cols, ok := columns[t.name]
+			if !ok || emitErr != nil {
+				return
+			}
+			b, ok := batches[t.name]
+			if !ok {
+				b = &Insert{Table: t.name, Columns: cols}
+				batches[t.name] = b
+			}
+			row := make([]interface{}, len(cols))
+			for i, col := range cols {
+				row[i] = jsonValue(values[col], types[t.name][i])
+			}
+			b.Rows = append(b.Rows, row)
+			if len(b.Rows) >= copyBatchRows {
+				emitErr = flushAll()
+			}
+		})
+		return emitErr
+	})
+	if err != nil {
+		return err
+	}
+	return flushAll()
+}
+
+func jsonValue(v interface{}, mysqlType string) interface{} {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case int64:
+		return val
+	}
+	if mysqlType == "json" {
+		if s, ok := v.(string); ok {
+			b, _ := json.Marshal(s)
+			return string(b)
+		}
+	}
+	return csvValue(jsonText(v), mysqlType)
+}
@@ -0,0 +1,413 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// StatementReader splits a SQL dump into complete statements while streaming
+// it, so the file is never loaded into memory as a whole. Quoted strings,
+// quoted identifiers and comments are respected: a ';' or ");" inside them
+// never ends a statement. MySQL conditional comments (/*!40101 ... */) are
+// unwrapped and their body is kept as part of the statement.
+type StatementReader struct {
+	r         *lineReader
+	delimiter string
+	buf       bytes.Buffer
+	inVersion bool
+
+	// Line of the first byte of the statement being read and of the last
+	// one returned.
+	stmtLine, lastLine int
+
+	dialect     dialect
+	copyPending bool
+}
+
+// dialect selects the lexing rules. PostgreSQL and SQLite use standard
+// conforming strings; PostgreSQL adds E'...' strings, dollar quoting and
+// COPY ... FROM stdin data blocks.
+type dialect int
+
+const (
+	dialectMySQL dialect = iota
+	dialectPostgres
+	dialectSQLite
+)
+
+func NewStatementReader(r io.Reader) *StatementReader {
+	return &StatementReader{
+		r:         &lineReader{Reader: bufio.NewReaderSize(r, 1<<20), line: 1},
+		delimiter: ";",
+	}
+}
+
+// Line returns the 1-based line on which the statement last returned by Next
+// starts.
+func (s *StatementReader) Line() int {
+	return s.lastLine
+}
+
+// lineReader counts the lines consumed from the input.
+type lineReader struct {
+	*bufio.Reader
+	line int
+}
+
+func (l *lineReader) ReadByte() (byte, error) {
+	c, err := l.Reader.ReadByte()
+	if err == nil && c == '\n' {
+		l.line++
+	}
+	return c, err
+}
+
+func (l *lineReader) ReadString(delim byte) (string, error) {
+	line, err := l.Reader.ReadString(delim)
+	l.line += strings.Count(line, "\n")
+	return line, err
+}
+
+func (l *lineReader) Discard(n int) (int, error) {
+	b, _ := l.Reader.Peek(n)
+	l.line += bytes.Count(b, []byte{'\n'})
+	return l.Reader.Discard(n)
+}
+
+func NewPostgresStatementReader(r io.Reader) *StatementReader {
+	s := NewStatementReader(r)
+	s.dialect = dialectPostgres
+	return s
+}
+
+func NewSQLiteStatementReader(r io.Reader) *StatementReader {
+	s := NewStatementReader(r)
+	s.dialect = dialectSQLite
+	return s
+}
+
+// Next returns the next statement without its trailing delimiter.
+// It returns io.EOF once the input is exhausted.
+func (s *StatementReader) Next() (string, error) {
+	if s.copyPending {
+		if err := s.CopyData(nil); err != nil {
+			return "", err
+		}
+	}
+
+	s.buf.Reset()
+	s.stmtLine = 0
+	for {
+		line, start := s.r.line, s.buf.Len()
+		c, err := s.r.ReadByte()
+		if err == io.EOF {
+			stmt := strings.TrimSpace(s.buf.String())
+			s.buf.Reset()
+			if stmt != "" {
+				s.lastLine = s.stmtLine
+				return stmt, nil
+			}
+			return "", io.EOF
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch {
+		case c == '\'':
+			if err := s.readQuoted(c, s.dialect == dialectMySQL || (s.dialect == dialectPostgres && s.atEscapeString())); err != nil {
+				return "", err
+			}
+
+		case c == '"' || c == '`':
+			if err := s.readQuoted(c, s.dialect == dialectMySQL && c == '"'); err != nil {
+				return "", err
+			}
+
+		case c == '$' && s.dialect == dialectPostgres:
+			if err := s.readDollarQuoted(); err != nil {
+				return "", err
+			}
+
+		case c == '#' && s.dialect == dialectMySQL:
+			if err := s.skipLine(); err != nil {
+				return "", err
+			}
+
+		case c == '-' && s.atDashComment():
+			if err := s.skipLine(); err != nil {
+				return "", err
+			}
+
+		case c == '/' && s.peekByte() == '*':
+			s.r.ReadByte()
+			if s.peekByte() == '!' && s.dialect == dialectMySQL {
+				s.r.ReadByte()
+				s.skipVersion()
+				s.inVersion = true
+				continue
+			}
+			if err := s.skipBlockComment(); err != nil {
+				return "", err
+			}
+			s.buf.WriteByte(' ')
+
+		case c == '*' && s.inVersion && s.peekByte() == '/':
+			s.r.ReadByte()
+			s.inVersion = false
+			s.buf.WriteByte(' ')
+
+		case (c == 'D' || c == 'd') && s.dialect == dialectMySQL && s.atDelimiterCommand():
+			if err := s.readDelimiterCommand(); err != nil {
+				return "", err
+			}
+
+		case s.atDelimiter(c):
+			stmt := strings.TrimSpace(s.buf.String())
+			s.buf.Reset()
+			if stmt != "" {
+				s.copyPending = s.dialect == dialectPostgres && isCopyFromStdin(stmt)
+				s.lastLine = s.stmtLine
+				s.stmtLine = 0
+				return stmt, nil
+			}
+			s.stmtLine = 0
+
+		default:
+			s.buf.WriteByte(c)
+		}
+
+		if s.stmtLine == 0 && s.buf.Len() > start && len(bytes.TrimSpace(s.buf.Bytes()[start:])) > 0 {
+			s.stmtLine = line
+		}
+	}
+}
+
+func (s *StatementReader) peekByte() byte {
+	b, err := s.r.Peek(1)
+	if err != nil || len(b) == 0 {
+		return 0
+	}
+	return b[0]
+}
+
+// In MySQL "--" only starts a comment when followed by whitespace or end of
+// input; the other dialects have no such rule.
+func (s *StatementReader) atDashComment() bool {
+	b, _ := s.r.Peek(2)
+	if len(b) == 0 || b[0] != '-' {
+		return false
+	}
+	return s.dialect != dialectMySQL || len(b) == 1 || b[1] == ' ' || b[1] == '\t' || b[1] == '\n' || b[1] == '\r'
+}
+
+func (s *StatementReader) atDelimiter(c byte) bool {
+	d := s.delimiter
+	if c != d[0] {
+		return false
+	}
+	if len(d) == 1 {
+		return true
+	}
+	b, _ := s.r.Peek(len(d) - 1)
+	if string(b) != d[1:] {
+		return false
+	}
+	s.r.Discard(len(d) - 1)
+	return true
+}
+
+// atDelimiterCommand reports whether a mysql client "DELIMITER ;;" line
+// starts here, as found in dumps containing triggers and routines.
+func (s *StatementReader) atDelimiterCommand() bool {
+	if s.buf.Len() > 64 || len(bytes.TrimSpace(s.buf.Bytes())) > 0 {
+		return false
+	}
+	b, _ := s.r.Peek(9)
+	return len(b) == 9 && strings.EqualFold(string(b[:8]), "ELIMITER") && (b[8] == ' ' || b[8] == '\t')
+}
+
+func (s *StatementReader) readDelimiterCommand() error {
+	line, err := s.r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if d := strings.TrimSpace(line[8:]); d != "" {
+		s.delimiter = d
+	}
+	s.buf.Reset()
+	return nil
+}
+
+// atEscapeString reports whether the quote being opened belongs to a
+// PostgreSQL E'...' literal, the only kind where backslash escapes apply.
+func (s *StatementReader) atEscapeString() bool {
+	b := s.buf.Bytes()
+	if len(b) == 0 || (b[len(b)-1] != 'E' && b[len(b)-1] != 'e') {
+		return false
+	}
+	return len(b) == 1 || !isWordByte(b[len(b)-2])
+}
+
+func (s *StatementReader) readQuoted(q byte, escapes bool) error {
+	s.buf.WriteByte(q)
+	for {
+		c, err := s.r.ReadByte()
+		if err == io.EOF {
+			return fmt.Errorf("unterminated %c quoted literal", q)
+		}
+		if err != nil {
+			return err
+		}
+		s.buf.WriteByte(c)
+
+		if c == '\\' && escapes {
+			next, err := s.r.ReadByte()
+			if err != nil {
+				return fmt.Errorf("unterminated %c quoted literal", q)
+			}
+			s.buf.WriteByte(next)
+			continue
+		}
+		if c == q {
+			if s.peekByte() == q {
+				next, _ := s.r.ReadByte()
+				s.buf.WriteByte(next)
+				continue
+			}
+			return nil
+		}
+	}
+}
+
+// readDollarQuoted copies a PostgreSQL $tag$ ... $tag$ body, as used by
+// function definitions. A '$' that does not open such a quote is kept as is.
+func (s *StatementReader) readDollarQuoted() error {
+	b := s.buf.Bytes()
+	if len(b) > 0 && isWordByte(b[len(b)-1]) {
+		s.buf.WriteByte('$')
+		return nil
+	}
+
+	peek, _ := s.r.Peek(64)
+	end := bytes.IndexByte(peek, '$')
+	if end < 0 || (end > 0 && peek[0] >= '0' && peek[0] <= '9') {
+		s.buf.WriteByte('$')
+		return nil
+	}
+	for _, c := range peek[:end] {
+		if !isWordByte(c) || c == '$' {
+			s.buf.WriteByte('$')
+			return nil
+		}
+	}
+
+	tag := "$" + string(peek[:end]) + "$"
+	s.buf.WriteString(tag)
+	s.r.Discard(end + 1)
+
+	start := s.buf.Len()
+	for {
+		c, err := s.r.ReadByte()
+		if err == io.EOF {
+			return fmt.Errorf("unterminated %s quoted literal", tag)
+		}
+		if err != nil {
+			return err
+		}
+		s.buf.WriteByte(c)
+		if c == '$' && s.buf.Len()-start >= len(tag) && bytes.HasSuffix(s.buf.Bytes(), []byte(tag)) {
+			return nil
+		}
+	}
+}
+
+// CopyData hands the data lines that follow a "COPY ... FROM stdin"
+// statement returned by Next to fn, up to the terminating "\." line. With a
+// nil fn the block is skipped.
+func (s *StatementReader) CopyData(fn func(line string) error) error {
+	if !s.copyPending {
+		return nil
+	}
+	s.copyPending = false
+
+	// Rest of the line holding the COPY statement.
+	if _, err := s.r.ReadString('\n'); err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == `\.` {
+			return nil
+		}
+		if fn != nil && (line != "" || err == nil) {
+			if ferr := fn(line); ferr != nil {
+				return ferr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+func isCopyFromStdin(stmt string) bool {
+	if len(stmt) < 5 || !strings.EqualFold(stmt[:5], "COPY ") {
+		return false
+	}
+	return strings.HasSuffix(strings.ToUpper(strings.Join(strings.Fields(stmt), " ")), "FROM STDIN")
+}
+
+func (s *StatementReader) skipLine() error {
+	for {
+		c, err := s.r.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if c == '\n' {
+			s.buf.WriteByte('\n')
+			return nil
+		}
+	}
+}
+
+func (s *StatementReader) skipBlockComment() error {
+	var prev byte
+	for {
+		c, err := s.r.ReadByte()
+		if err == io.EOF {
+			return fmt.Errorf("unterminated block comment")
+		}
+		if err != nil {
+			return err
+		}
+		if prev == '*' && c == '/' {
+			return nil
+		}
+		prev = c
+	}
+}
+
+func (s *StatementReader) skipVersion() {
+	for {
+		c := s.peekByte()
+		if c < '0' || c > '9' {
+			return
+		}
+		s.r.ReadByte()
+	}
+}
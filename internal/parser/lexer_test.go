@@ -0,0 +1,332 @@
+package parser
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// readStatements returns every statement of r, skipping COPY data blocks.
+func readStatements(t *testing.T, r *StatementReader) []string {
+	t.Helper()
+	var stmts []string
+	for {
+		stmt, err := r.Next()
+		if err == io.EOF {
+			return stmts
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		stmts = append(stmts, stmt)
+	}
+}
+
+// readers feeds the input whole, one byte at a time and in halves, so
+// every token also gets split across reads.
+var readers = []struct {
+	name string
+	wrap func(io.Reader) io.Reader
+}{
+	{"whole", func(r io.Reader) io.Reader { return r }},
+	{"one byte", iotest.OneByteReader},
+	{"half", iotest.HalfReader},
+}
+
+func TestStatementReader(t *testing.T) {
+	mysql, postgres, sqlite := NewStatementReader, NewPostgresStatementReader, NewSQLiteStatementReader
+
+	tests := []struct {
+		name  string
+		newR  func(io.Reader) *StatementReader
+		input string
+		want  []string
+	}{
+		{
+			name:  "statements",
+			newR:  mysql,
+			input: "SELECT 1;\n\nSELECT 2;\n",
+			want:  []string{"SELECT 1", "SELECT 2"},
+		},
+		{
+			name:  "no trailing delimiter",
+			newR:  mysql,
+			input: "SELECT 1;\nSELECT 2\n",
+			want:  []string{"SELECT 1", "SELECT 2"},
+		},
+		{
+			name:  "delimiter inside quotes",
+			newR:  mysql,
+			input: "INSERT INTO `a;b` VALUES ('x;y',\"p;q\");SELECT 2;",
+			want:  []string{"INSERT INTO `a;b` VALUES ('x;y',\"p;q\")", "SELECT 2"},
+		},
+		{
+			name:  "backslash escapes",
+			newR:  mysql,
+			input: `INSERT INTO t VALUES ('it\'s;',"a\";b",'c\\');SELECT 2;`,
+			want:  []string{`INSERT INTO t VALUES ('it\'s;',"a\";b",'c\\')`, "SELECT 2"},
+		},
+		{
+			name:  "doubled quotes",
+			newR:  mysql,
+			input: "INSERT INTO t VALUES ('it''s;');SELECT 2;",
+			want:  []string{"INSERT INTO t VALUES ('it''s;')", "SELECT 2"},
+		},
+		{
+			name:  "comments",
+			newR:  mysql,
+			input: "-- one;\nSELECT 1; # two;\n/* three; */SELECT 2;",
+			want:  []string{"SELECT 1", "SELECT 2"},
+		},
+		{
+			name:  "double dash without space",
+			newR:  mysql,
+			input: "SELECT 1--1;",
+			want:  []string{"SELECT 1--1"},
+		},
+		{
+			name:  "conditional comment",
+			newR:  mysql,
+			input: "/*!40101 SET NAMES utf8mb4 */;\nCREATE TABLE t (id int) /*!50100 PARTITION BY HASH (id) */;",
+			want:  []string{"SET NAMES utf8mb4", "CREATE TABLE t (id int)  PARTITION BY HASH (id)"},
+		},
+		{
+			name: "DELIMITER",
+			newR: mysql,
+			input: "DELIMITER ;;\n" +
+				"CREATE TRIGGER tr BEFORE INSERT ON t FOR EACH ROW BEGIN SET NEW.a = 1; END ;;\n" +
+				"DELIMITER ;\n" +
+				"SELECT 1;",
+			want: []string{"CREATE TRIGGER tr BEFORE INSERT ON t FOR EACH ROW BEGIN SET NEW.a = 1; END", "SELECT 1"},
+		},
+		{
+			name:  "DELIMITER lowercase",
+			newR:  mysql,
+			input: "delimiter $$\nSELECT 1; SELECT 2$$\ndelimiter ;\nSELECT 3;",
+			want:  []string{"SELECT 1; SELECT 2", "SELECT 3"},
+		},
+		{
+			name:  "dollar quoting",
+			newR:  postgres,
+			input: "CREATE FUNCTION f() RETURNS int AS $body$ SELECT 1; $x$ $body$ LANGUAGE sql;\nSELECT 2;",
+			want:  []string{"CREATE FUNCTION f() RETURNS int AS $body$ SELECT 1; $x$ $body$ LANGUAGE sql", "SELECT 2"},
+		},
+		{
+			name:  "empty dollar tag",
+			newR:  postgres,
+			input: "DO $$ BEGIN PERFORM 1; END $$;",
+			want:  []string{"DO $$ BEGIN PERFORM 1; END $$"},
+		},
+		{
+			name:  "dollar without tag",
+			newR:  postgres,
+			input: "PREPARE p AS SELECT $1; SELECT a$b;",
+			want:  []string{"PREPARE p AS SELECT $1", "SELECT a$b"},
+		},
+		{
+			name:  "standard conforming strings",
+			newR:  postgres,
+			input: `SELECT 'a\'; SELECT E'b\';c';`,
+			want:  []string{`SELECT 'a\'`, `SELECT E'b\';c'`},
+		},
+		{
+			name:  "no conditional comments in postgres",
+			newR:  postgres,
+			input: "/*!40101 SET x = 1; */SELECT 1;",
+			want:  []string{"SELECT 1"},
+		},
+		{
+			name:  "sqlite quoting",
+			newR:  sqlite,
+			input: `INSERT INTO "a""b" VALUES('c\',"d;");--x;` + "\nSELECT 2;",
+			want:  []string{`INSERT INTO "a""b" VALUES('c\',"d;")`, "SELECT 2"},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, rd := range readers {
+			t.Run(tt.name+"/"+rd.name, func(t *testing.T) {
+				got := readStatements(t, tt.newR(rd.wrap(strings.NewReader(tt.input))))
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("got %q, want %q", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestStatementReaderErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		newR  func(io.Reader) *StatementReader
+		input string
+		want  string
+	}{
+		{"string", NewStatementReader, "SELECT 'abc;", "unterminated ' quoted literal"},
+		{"identifier", NewStatementReader, "SELECT `abc;", "unterminated ` quoted literal"},
+		{"block comment", NewStatementReader, "SELECT 1 /* x;", "unterminated block comment"},
+		{"dollar quote", NewPostgresStatementReader, "DO $f$ BEGIN; END;", "unterminated $f$ quoted literal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.newR(strings.NewReader(tt.input)).Next()
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatementReaderCopy(t *testing.T) {
+	input := "SET client_encoding = 'UTF8';\n" +
+		"COPY public.t (id, name) FROM stdin;\n" +
+		"1\ta;b\n" +
+		"2\t\\N\n" +
+		"\\.\n" +
+		"COPY public.u (id) FROM stdin;\n" +
+		"3; DROP TABLE t;\n" +
+		"\\.\n" +
+		"SELECT 1;\n"
+
+	tests := []struct {
+		name      string
+		readData  bool
+		wantStmts []string
+		wantData  []string
+	}{
+		{
+			name:      "read",
+			readData:  true,
+			wantStmts: []string{"SET client_encoding = 'UTF8'", "COPY public.t (id, name) FROM stdin", "COPY public.u (id) FROM stdin", "SELECT 1"},
+			wantData:  []string{"1\ta;b", "2\t\\N", "3; DROP TABLE t;"},
+		},
+		{
+			name:      "skipped",
+			wantStmts: []string{"SET client_encoding = 'UTF8'", "COPY public.t (id, name) FROM stdin", "COPY public.u (id) FROM stdin", "SELECT 1"},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, rd := range readers {
+			t.Run(tt.name+"/"+rd.name, func(t *testing.T) {
+				r := NewPostgresStatementReader(rd.wrap(strings.NewReader(input)))
+				var stmts, data []string
+				for {
+					stmt, err := r.Next()
+					if err == io.EOF {
+						break
+					}
+					if err != nil {
+						t.Fatalf("Next: %v", err)
+					}
+					stmts = append(stmts, stmt)
+					if tt.readData {
+						err := r.CopyData(func(line string) error {
+							data = append(data, line)
+							return nil
+						})
+						if err != nil {
+							t.Fatalf("CopyData: %v", err)
+						}
+					}
+				}
+				if !reflect.DeepEqual(stmts, tt.wantStmts) {
+					t.Errorf("statements: got %q, want %q", stmts, tt.wantStmts)
+				}
+				if !reflect.DeepEqual(data, tt.wantData) {
+					t.Errorf("data: got %q, want %q", data, tt.wantData)
+				}
+			})
+		}
+	}
+}
+
+func TestStatementReaderLine(t *testing.T) {
+	input := "-- header\n\nSELECT 1;\nINSERT INTO t VALUES\n('a\nb');\n\n  SELECT 2;"
+	want := []int{3, 4, 8}
+
+	r := NewStatementReader(strings.NewReader(input))
+	var got []int
+	for {
+		_, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		got = append(got, r.Line())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got lines %v, want %v", got, want)
+	}
+}
+
+// TestStatementReaderBufferBoundary places quotes, dollar tags and
+// delimiters across the end of the reader's 1 MiB buffer.
+func TestStatementReaderBufferBoundary(t *testing.T) {
+	const bufSize = 1 << 20
+
+	// pad returns a statement prefix that puts the token at offset
+	// bufSize+shift of the input.
+	pad := func(head string, shift int) string {
+		return head + strings.Repeat("x", bufSize+shift-len(head))
+	}
+
+	tests := []struct {
+		name  string
+		newR  func(io.Reader) *StatementReader
+		input string
+		want  []string
+	}{
+		{
+			name:  "doubled quote",
+			newR:  NewStatementReader,
+			input: pad("SELECT '", -1) + "'';';SELECT 2;",
+			want:  []string{pad("SELECT '", -1) + "'';'", "SELECT 2"},
+		},
+		{
+			name:  "escaped quote",
+			newR:  NewStatementReader,
+			input: pad("SELECT '", -1) + `\';';SELECT 2;`,
+			want:  []string{pad("SELECT '", -1) + `\';'`, "SELECT 2"},
+		},
+		{
+			name:  "two byte delimiter",
+			newR:  NewStatementReader,
+			input: "DELIMITER ;;\n" + pad("SELECT 1; -- ", -15) + "\n;;SELECT 2;;",
+			want:  []string{"SELECT 1;", "SELECT 2"},
+		},
+		{
+			name:  "dollar tag",
+			newR:  NewPostgresStatementReader,
+			input: pad("DO ", -4) + " $body$ ; $body$;SELECT 2;",
+			want:  []string{pad("DO ", -4) + " $body$ ; $body$", "SELECT 2"},
+		},
+		{
+			name:  "conditional comment",
+			newR:  NewStatementReader,
+			input: pad("SELECT 1 -- ", -2) + "\n/*!40101 + 1 */;SELECT 2;",
+			want:  []string{"SELECT 1 \n + 1", "SELECT 2"},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, rd := range readers[:2] {
+			t.Run(tt.name+"/"+rd.name, func(t *testing.T) {
+				got := readStatements(t, tt.newR(rd.wrap(strings.NewReader(tt.input))))
+				if len(got) != len(tt.want) {
+					t.Fatalf("got %d statements, want %d", len(got), len(tt.want))
+				}
+				for i := range got {
+					if got[i] != tt.want[i] {
+						t.Errorf("statement %d: got %.40q (len %d), want %.40q (len %d)", i, got[i], len(got[i]), tt.want[i], len(tt.want[i]))
+					}
+				}
+			})
+		}
+	}
+}
@@ -1,9 +1,10 @@
 package parser
 
 import (
-	"bufio"
+	"context"
+	"fmt"
+	"io"
 	"log"
-	"os"
 	"regexp"
 	"strings"
 )
@@ -23,121 +24,93 @@ type Field struct {
 	AutoIncrement bool            `json:"auto_increment,omitempty"`
 	Index         bool            `json:"index,omitempty"`
 	ForeignKey    *ForeignKeyMeta `json:"foreign_key,omitempty"`
+	TypeInfo      ColumnType      `json:"type_info"`
 }
 
 type ParsedTable struct {
-	TableName   string   `json:"table_name"`
-	Fields      []Field  `json:"fields"`
-	UniqueKeys  []string `json:"unique_keys,omitempty"`
-	Engine      string   `json:"engine,omitempty"`
-	Charset     string   `json:"charset,omitempty"`
-	PrimaryKeys []string `json:"primary_keys,omitempty"`
-	Inserts     []string `json:"inserts,omitempty"` // eklendi
+	TableName   string       `json:"table_name"`
+	Fields      []Field      `json:"fields"`
+	UniqueKeys  []string     `json:"unique_keys,omitempty"`
+	Engine      string       `json:"engine,omitempty"`
+	Charset     string       `json:"charset,omitempty"`
+	PrimaryKeys []string     `json:"primary_keys,omitempty"`
+	Indexes     []Index      `json:"indexes,omitempty"`
+	ForeignKeys []ForeignKey `json:"foreign_keys,omitempty"`
+	RowCount    int64        `json:"row_count"`
 }
 
-func ParseSQLFile(filePath string) ([]ParsedTable, error) {
-	file, err := os.Open(filePath)
+func ParseSQLFile(ctx context.Context, filePath string) ([]ParsedTable, error) {
+	file, err := openInput(ctx, filePath)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
 
-	var tables []ParsedTable
-	var inserts []string
-
-	scanner := bufio.NewScanner(file)
-	buf := make([]byte, 0, 10*1024*1024)
-	scanner.Buffer(buf, 10*1024*1024)
-
-	var insideCreate bool
-	var insideAlter bool
-	var createLines []string
-	var alterLines []string
+	return ParseSQL(file)
+}
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		upper := strings.ToUpper(line)
+func ParseSQL(r io.Reader) ([]ParsedTable, error) {
+	var tables []ParsedTable
+	tableIndex := map[string]int{}
 
-		if strings.HasPrefix(upper, "CREATE TABLE") {
-			insideCreate = true
-			createLines = []string{line}
-			continue
+	reader := NewStatementReader(r)
+	for {
+		stmt, err := reader.Next()
+		if err == io.EOF {
+			break
 		}
-
-		if insideCreate {
-			createLines = append(createLines, line)
-			if strings.HasSuffix(line, ");") {
-				table, err := parseCreateBlock(createLines)
-				if err == nil {
-					tables = append(tables, table)
-				}
-				insideCreate = false
-			}
-			continue
+		if err != nil {
+			return nil, err
 		}
 
-		if strings.HasPrefix(upper, "ALTER TABLE") {
-			insideAlter = true
-			alterLines = []string{line}
-			continue
-		}
+		upper := strings.ToUpper(stmt[:min(len(stmt), 32)])
 
-		if insideAlter {
-			alterLines = append(alterLines, line)
-			if strings.HasSuffix(line, ";") {
-				joined := strings.Join(alterLines, " ")
-				parseAlterStatement(joined, &tables)
-				insideAlter = false
+		switch {
+		case strings.HasPrefix(upper, "CREATE TABLE"):
+			table, err := parseCreateBlock(strings.Split(stmt, "\n"))
+			if err == nil {
+				tableIndex[table.TableName] = len(tables)
+				tables = append(tables, table)
 			}
-			continue
-		}
-	}
 
-	// Dosyanın tamamını oku ve regex ile INSERT INTO bloklarını yakala
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return nil, err
-	}
-	sqlText := string(content)
-
-	reInsert := regexp.MustCompile(`(?is)INSERT INTO\s+.*?(?:;|LOCK TABLES|UNLOCK TABLES|ALTER TABLE)`)
-	matches := reInsert.FindAllString(sqlText, -1)
-	if len(matches) > 0 {
-		inserts = append(inserts, matches...)
-	}
+		case strings.HasPrefix(upper, "ALTER TABLE"):
+			parseAlterStatement(strings.Join(strings.Fields(stmt), " "), &tables)
 
-	for _, insert := range inserts {
-		parts := strings.SplitN(insert, " ", 4)
-		if len(parts) > 2 {
-			tableName := strings.Trim(parts[2], "`")
-			for i := range tables {
-				if tables[i].TableName == tableName {
-					tables[i].Inserts = append(tables[i].Inserts, insert)
-				}
+		case isInsertStatement(stmt):
+			ins, err := ParseInsert(stmt)
+			if err != nil {
+				return nil, fmt.Errorf("insert parse error at line %d: %v", reader.Line(), err)
+			}
+			if i, ok := tableIndex[ins.Table]; ok {
+				tables[i].RowCount += int64(len(ins.Rows))
 			}
+
+		default:
+			logSkippedObject(stmt)
 		}
 	}
 
-	totalInserts := 0
+	var totalRows int64
 	for _, t := range tables {
-		totalInserts += len(t.Inserts)
+		totalRows += t.RowCount
 	}
-	log.Printf("Parsed %d tables, total %d inserts", len(tables), totalInserts)
+	log.Printf("Parsed %d tables, total %d rows", len(tables), totalRows)
 
 	for _, t := range tables {
-		if len(t.Inserts) == 0 {
-			log.Printf("Table with no inserts: %s", t.TableName)
+		if t.RowCount == 0 {
+			log.Printf("Table with no rows: %s", t.TableName)
 		}
 	}
 
-	return tables, scanner.Err()
+	return tables, nil
 }
 
 func parseCreateBlock(lines []string) (ParsedTable, error) {
 	var table ParsedTable
 	var fields []Field
 	var primaryKeys []string
-	var uniqueKeys []string
+	var indexes []Index
+	var foreignKeys []ForeignKey
 
 	stmt := strings.Join(lines, " ")
 	reTable := regexp.MustCompile("(?i)CREATE TABLE\\s+`([^`]+)`")
@@ -154,17 +127,10 @@ func parseCreateBlock(lines []string) (ParsedTable, error) {
 		table.PrimaryKeys = primaryKeys
 	}
 
-	uqRe := regexp.MustCompile("(?i)UNIQUE KEY\\s+`[^`]+`\\s*\\(`([^`]+)`\\)")
-	uqMatches := uqRe.FindAllStringSubmatch(stmt, -1)
-	for _, m := range uqMatches {
-		if len(m) >= 2 {
-			uniqueKeys = append(uniqueKeys, m[1])
-		}
-	}
-	table.UniqueKeys = uniqueKeys
-
 	engineRe := regexp.MustCompile(`ENGINE=([a-zA-Z0-9]+)`)
 	charsetRe := regexp.MustCompile(`CHARSET=([a-zA-Z0-9_]+)`)
+	colCharsetRe := regexp.MustCompile(`(?i)\bCHARACTER SET\s+([a-zA-Z0-9_]+)`)
+	collateRe := regexp.MustCompile(`(?i)\bCOLLATE\s+([a-zA-Z0-9_]+)`)
 
 	if m := engineRe.FindStringSubmatch(stmt); len(m) >= 2 {
 		table.Engine = m[1]
@@ -175,15 +141,34 @@ func parseCreateBlock(lines []string) (ParsedTable, error) {
 
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
-		colRe := regexp.MustCompile("^`([^`]+)`\\s+([a-zA-Z0-9()]+)(.*)")
+		colRe := regexp.MustCompile("^`([^`]+)`\\s+(.*)")
+		if ix, ok := parseMySQLIndex(strings.TrimSuffix(line, ",")); ok {
+			indexes = append(indexes, ix)
+			continue
+		}
+		if fk, ok := parseForeignKey(strings.TrimSuffix(line, ",")); ok {
+			foreignKeys = append(foreignKeys, fk)
+			continue
+		}
 		if matches := colRe.FindStringSubmatch(line); len(matches) >= 3 {
 			name := matches[1]
-			typeStr := matches[2]
-			extra := matches[3]
+			typeStr, extra := readColumnType(matches[2])
+			if typeStr == "" {
+				continue
+			}
+
+			typeInfo := ParseColumnType(typeStr)
+			if m := colCharsetRe.FindStringSubmatch(extra); len(m) >= 2 {
+				typeInfo.Charset = m[1]
+			}
+			if m := collateRe.FindStringSubmatch(extra); len(m) >= 2 {
+				typeInfo.Collation = m[1]
+			}
 
 			field := Field{
 				Name:          name,
 				Type:          typeStr,
+				TypeInfo:      typeInfo,
 				Nullable:      !strings.Contains(strings.ToUpper(extra), "NOT NULL"),
 				Default:       extractDefault(extra),
 				AutoIncrement: strings.Contains(strings.ToUpper(extra), "AUTO_INCREMENT"),
@@ -194,17 +179,17 @@ func parseCreateBlock(lines []string) (ParsedTable, error) {
 					field.PrimaryKey = true
 				}
 			}
-			for _, uq := range uniqueKeys {
-				if strings.EqualFold(field.Name, uq) {
-					field.Unique = true
-				}
-			}
-
 			fields = append(fields, field)
 		}
 	}
 
 	table.Fields = fields
+	for _, ix := range indexes {
+		table.addIndex(ix)
+	}
+	for _, fk := range foreignKeys {
+		table.addForeignKey(fk)
+	}
 	return table, nil
 }
 
@@ -243,37 +228,24 @@ func parseAlterStatement(line string, tables *[]ParsedTable) {
 		}
 	}
 
-	addKeyRe := regexp.MustCompile(`(?i)ALTER TABLE\s+` + "`" + `([^` + "`" + `]+)` + "`" + `.*ADD KEY\s+` + "`" + `[^` + "`" + `]+` + "`" + `\s*\(([^)]+)\)`)
-	if matches := addKeyRe.FindStringSubmatch(line); len(matches) >= 3 {
-		tableName := matches[1]
-		columns := strings.Split(matches[2], ",")
+	// phpMyAdmin dumps add all keys and foreign keys of a table in one
+	// ALTER TABLE; they belong to that table only.
+	alterRe := regexp.MustCompile("(?is)^ALTER TABLE\\s+`([^`]+)`\\s+(.*?);?$")
+	if matches := alterRe.FindStringSubmatch(line); len(matches) >= 3 {
 		for i := range *tables {
-			if (*tables)[i].TableName == tableName {
-				for _, col := range columns {
-					col = strings.Trim(col, "` ")
-					for j := range (*tables)[i].Fields {
-						if strings.EqualFold((*tables)[i].Fields[j].Name, col) {
-							(*tables)[i].Fields[j].Index = true
-						}
-					}
-				}
+			if (*tables)[i].TableName != matches[1] {
+				continue
 			}
-		}
-	}
-
-	fkRe := regexp.MustCompile(`(?i)ADD CONSTRAINT\s+` + "`" + `[^` + "`" + `]+` + "`" + `\s+FOREIGN KEY\s+\(` + "`" + `([^` + "`" + `]+)` + "`" + `\)\s+REFERENCES\s+` + "`" + `([^` + "`" + `]+)` + "`" + `\s+\(` + "`" + `([^` + "`" + `]+)` + "`" + `\)`)
-	if matches := fkRe.FindStringSubmatch(line); len(matches) >= 4 {
-		sourceField := matches[1]
-		targetTable := matches[2]
-		targetField := matches[3]
-
-		for i := range *tables {
-			for j := range (*tables)[i].Fields {
-				if strings.EqualFold((*tables)[i].Fields[j].Name, sourceField) {
-					(*tables)[i].Fields[j].ForeignKey = &ForeignKeyMeta{
-						ReferencedTable: targetTable,
-						ReferencedField: targetField,
-					}
+			for _, clause := range splitTopLevel(matches[2]) {
+				clause = strings.TrimSpace(clause)
+				if len(clause) < 4 || !strings.EqualFold(clause[:4], "ADD ") {
+					continue
+				}
+				if ix, ok := parseMySQLIndex(strings.TrimSpace(clause[4:])); ok {
+					(*tables)[i].addIndex(ix)
+				}
+				if fk, ok := parseForeignKey(clause[4:]); ok {
+					(*tables)[i].addForeignKey(fk)
 				}
 			}
 		}
@@ -281,6 +253,17 @@ func parseAlterStatement(line string, tables *[]ParsedTable) {
 }
 
 func extractDefault(extra string) string {
+	// Quoted defaults are read whole: enum and set defaults such as
+	// 'b c' or 'red,blue' hold spaces and commas.
+	// Bit and hex literals keep their prefix: b'0' is not the string '0'.
+	literalRe := regexp.MustCompile(`(?i)DEFAULT\s+([bx]'[0-9a-f]*')`)
+	if matches := literalRe.FindStringSubmatch(extra); len(matches) >= 2 {
+		return strings.ToLower(matches[1][:1]) + matches[1][1:]
+	}
+	quotedRe := regexp.MustCompile(`(?i)DEFAULT\s+('(?:[^'\\]|''|\\.)*')`)
+	if matches := quotedRe.FindStringSubmatch(extra); len(matches) >= 2 {
+		return unquoteTypeValue(matches[1])
+	}
 	re := regexp.MustCompile(`(?i)DEFAULT\s+('?[^',\s]+'?)`)
 	matches := re.FindStringSubmatch(extra)
 	if len(matches) >= 2 {
@@ -297,3 +280,14 @@ func appendIfMissing(slice []string, val string) []string {
 	}
 	return append(slice, val)
 }
+
+// logSkippedObject logs the views, triggers, routines and events of a dump,
+// which are not converted: only tables are.
+func logSkippedObject(stmt string) {
+	re := regexp.MustCompile(`(?is)^CREATE\s+(?:OR\s+REPLACE\s+)?(?:ALGORITHM\s*=\s*\w+\s+)?(?:DEFINER\s*=\s*\S+\s+)?` +
+		`(?:SQL\s+SECURITY\s+\w+\s+)?(?:(?:MATERIALIZED|TEMP|TEMPORARY|CONSTRAINT)\s+)?` +
+		`(VIEW|TRIGGER|PROCEDURE|FUNCTION|EVENT)\s+(?:IF\s+NOT\s+EXISTS\s+)?` + identPattern)
+	if m := re.FindStringSubmatch(stmt); m != nil {
+		log.Printf("Skipped %s %s: only tables are converted", strings.ToLower(m[1]), unquoteIdent(m[2]))
+	}
+}
@@ -0,0 +1,44 @@
+package parser
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestLogSkippedObject(t *testing.T) {
+	tests := []struct {
+		stmt string
+		want string
+	}{
+		{"CREATE ALGORITHM=UNDEFINED DEFINER=`root`@`%` SQL SECURITY DEFINER VIEW `active_users` AS SELECT 1", "Skipped view active_users"},
+		{"CREATE DEFINER=`app`@`localhost` TRIGGER `users_bi` BEFORE INSERT ON `users` FOR EACH ROW SET NEW.a = 1", "Skipped trigger users_bi"},
+		{"CREATE PROCEDURE `cleanup`() BEGIN DELETE FROM t; END", "Skipped procedure cleanup"},
+		{"CREATE OR REPLACE FUNCTION public.touch() RETURNS trigger AS $$ BEGIN RETURN NEW; END $$ LANGUAGE plpgsql", "Skipped function touch"},
+		{"CREATE MATERIALIZED VIEW totals AS SELECT 1", "Skipped view totals"},
+		{"CREATE EVENT IF NOT EXISTS `purge` ON SCHEDULE EVERY 1 DAY DO DELETE FROM t", "Skipped event purge"},
+		{"CREATE INDEX idx_a ON t (a)", ""},
+		{"SET NAMES utf8mb4", ""},
+	}
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	defer func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(log.LstdFlags)
+	}()
+
+	for _, tt := range tests {
+		buf.Reset()
+		logSkippedObject(tt.stmt)
+		want := ""
+		if tt.want != "" {
+			want = tt.want + ": only tables are converted\n"
+		}
+		if got := buf.String(); got != want {
+			t.Errorf("%.40s: got %q, want %q", tt.stmt, got, want)
+		}
+	}
+}
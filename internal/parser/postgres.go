@@ -0,0 +1,474 @@
+package parser
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// copyBatchRows bounds how many COPY lines are handed on as one Insert.
+const copyBatchRows = 1000
+
+// ParsePostgresFile reads a pg_dump plain-format file into the same table
+// model as MySQL dumps. Column types are normalized to their MySQL spelling,
+// which is what every generator maps from.
+func ParsePostgresFile(ctx context.Context, filePath string) ([]ParsedTable, error) {
+	file, err := openInput(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ParsePostgres(file)
+}
+
+func ParsePostgres(r io.Reader) ([]ParsedTable, error) {
+	var tables []ParsedTable
+	tableIndex := map[string]int{}
+	find := func(name string) *ParsedTable {
+		if i, ok := tableIndex[name]; ok {
+			return &tables[i]
+		}
+		return nil
+	}
+
+	reader := NewPostgresStatementReader(r)
+	for {
+		stmt, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		upper := strings.ToUpper(stmt[:min(len(stmt), 32)])
+
+		switch {
+		case strings.HasPrefix(upper, "CREATE TABLE"), strings.HasPrefix(upper, "CREATE UNLOGGED TABLE"):
+			table, ok := parseCreateDefinitions(stmt, postgresToMySQLType)
+			if ok {
+				tableIndex[table.TableName] = len(tables)
+				tables = append(tables, table)
+			}
+
+		case strings.HasPrefix(upper, "ALTER TABLE"):
+			parsePostgresAlter(stmt, find)
+
+		case strings.HasPrefix(upper, "ALTER SEQUENCE"):
+			parsePostgresSequenceOwner(stmt, find)
+
+		case strings.HasPrefix(upper, "CREATE INDEX"), strings.HasPrefix(upper, "CREATE UNIQUE INDEX"):
+			parseCreateIndex(stmt, find)
+
+		case strings.HasPrefix(upper, "COPY "):
+			table, _, ok := parseCopyHeader(stmt)
+			var count int64
+			if err := reader.CopyData(func(string) error {
+				count++
+				return nil
+			}); err != nil {
+				return nil, err
+			}
+			if t := find(table); ok && t != nil {
+				t.RowCount += count
+			}
+
+		case strings.HasPrefix(upper, "INSERT"):
+			ins, err := parseInsert(stmt, true)
+			if err != nil {
+				return nil, fmt.Errorf("insert parse error at line %d: %v", reader.Line(), err)
+			}
+			if t := find(ins.Table); t != nil {
+				t.RowCount += int64(len(ins.Rows))
+			}
+
+		default:
+			logSkippedObject(stmt)
+		}
+	}
+
+	var totalRows int64
+	for _, t := range tables {
+		totalRows += t.RowCount
+	}
+	log.Printf("Parsed %d tables, total %d rows (pg_dump)", len(tables), totalRows)
+
+	return tables, nil
+}
+
+// PostgresDumpRows streams the COPY blocks and INSERT statements of a
+// pg_dump file. COPY text values are converted to the Go types the MySQL
+// reader produces, based on the column types of Tables.
+type PostgresDumpRows struct {
+	Path   string
+	Tables []ParsedTable
+}
+
+func (p *PostgresDumpRows) Each(ctx context.Context, fn func(Insert) error) error {
+	file, err := openInput(ctx, p.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	columns := map[string][]string{}
+	types := map[string]map[string]string{}
+	for _, t := range p.Tables {
+		names := make([]string, len(t.Fields))
+		colTypes := map[string]string{}
+		for i, f := range t.Fields {
+			names[i] = f.Name
+			colTypes[f.Name] = f.Type
+		}
+		columns[t.TableName] = names
+		types[t.TableName] = colTypes
+	}
+
+	reader := NewPostgresStatementReader(file)
+	for {
+		stmt, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		upper := strings.ToUpper(stmt[:min(len(stmt), 8)])
+		switch {
+		case strings.HasPrefix(upper, "COPY "):
+			table, cols, ok := parseCopyHeader(stmt)
+			if _, known := columns[table]; !ok || !known {
+				continue
+			}
+			if len(cols) == 0 {
+				cols = columns[table]
+			}
+			colTypes := make([]string, len(cols))
+			for i, c := range cols {
+				colTypes[i] = types[table][c]
+			}
+
+			batch := Insert{Table: table, Columns: cols}
+			err := reader.CopyData(func(line string) error {
+				fields := strings.Split(line, "\t")
+				row := make([]interface{}, len(cols))
+				for i := range row {
+					if i < len(fields) {
+						row[i] = copyValue(decodeCopyField(fields[i]), colTypes[i])
+					}
+				}
+				batch.Rows = append(batch.Rows, row)
+				if len(batch.Rows) >= copyBatchRows {
+					if err := fn(batch); err != nil {
+						return err
+					}
+					batch.Rows = nil
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if len(batch.Rows) > 0 {
+				if err := fn(batch); err != nil {
+					return err
+				}
+			}
+
+		case strings.HasPrefix(upper, "INSERT"):
+			ins, err := parseInsert(stmt, true)
+			if err != nil {
+				return fmt.Errorf("insert parse error at line %d: %v", reader.Line(), err)
+			}
+			cols, ok := columns[ins.Table]
+			if !ok {
+				continue
+			}
+			if len(ins.Columns) == 0 {
+				ins.Columns = cols
+			}
+			if err := fn(*ins); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// postgresToMySQLType spells a PostgreSQL column type the way a MySQL dump
+// would. The second result reports serial types.
+func postgresToMySQLType(pgType string) (string, bool) {
+	t := strings.ToLower(strings.TrimSpace(pgType))
+	t = strings.TrimPrefix(t, "pg_catalog.")
+	t = strings.TrimPrefix(t, "public.")
+
+	args := ""
+	if start := strings.Index(t, "("); start >= 0 {
+		if end := strings.Index(t[start:], ")"); end >= 0 {
+			args = strings.ReplaceAll(t[start:start+end+1], " ", "")
+		}
+	}
+
+	switch {
+	case strings.HasSuffix(t, "[]"):
+		return "longtext", false
+	case strings.HasPrefix(t, "character varying"), strings.HasPrefix(t, "varchar"):
+		if args == "" {
+			return "longtext", false
+		}
+		return "varchar" + args, false
+	case strings.HasPrefix(t, "character"), strings.HasPrefix(t, "char"), strings.HasPrefix(t, "bpchar"):
+		if args == "" {
+			args = "(1)"
+		}
+		return "char" + args, false
+	case strings.HasPrefix(t, "timestamp"):
+		return "datetime", false
+	case strings.HasPrefix(t, "time"):
+		return "time", false
+	case strings.HasPrefix(t, "double precision"):
+		return "double", false
+	case strings.HasPrefix(t, "bit varying"), strings.HasPrefix(t, "varbit"):
+		return "longtext", false
+	}
+
+	base := t
+	if i := strings.IndexAny(base, "( "); i >= 0 {
+		base = base[:i]
+	}
+	switch base {
+	case "smallint", "int2":
+		return "smallint", false
+	case "integer", "int", "int4":
+		return "int", false
+	case "bigint", "int8":
+		return "bigint", false
+	case "smallserial", "serial2":
+		return "smallint", true
+	case "serial", "serial4":
+		return "int", true
+	case "bigserial", "serial8":
+		return "bigint", true
+	case "boolean", "bool":
+		return "tinyint(1)", false
+	case "numeric", "decimal":
+		if args == "" {
+			return "decimal(65,30)", false
+		}
+		return "decimal" + args, false
+	case "real", "float4":
+		return "float", false
+	case "float8", "float":
+		return "double", false
+	case "date":
+		return "date", false
+	case "bytea":
+		return "longblob", false
+	case "json", "jsonb":
+		return "json", false
+	case "uuid":
+		return "char(36)", false
+	case "text", "citext", "name":
+		return "longtext", false
+	case "bit":
+		return "bit" + args, false
+	}
+	return "longtext", false
+}
+
+func parsePostgresAlter(stmt string, find func(string) *ParsedTable) {
+	reAlter := regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?(\S+)\s+(.*)$`)
+	m := reAlter.FindStringSubmatch(stmt)
+	if len(m) < 3 {
+		return
+	}
+	table := find(unquoteIdent(m[1]))
+	if table == nil {
+		return
+	}
+	action := m[2]
+
+	rePK := regexp.MustCompile(`(?is)ADD\s+(?:CONSTRAINT\s+\S+\s+)?PRIMARY\s+KEY\s*\(([^)]*)\)`)
+	reUnique := regexp.MustCompile(`(?is)ADD\s+(?:CONSTRAINT\s+(\S+)\s+)?UNIQUE\s*\(([^)]*)\)`)
+	reFK := regexp.MustCompile(`(?is)ADD\s+((?:CONSTRAINT\s+\S+\s+)?FOREIGN\s+KEY\b.*)$`)
+	reSerial := regexp.MustCompile(`(?is)ALTER\s+COLUMN\s+(\S+)\s+(?:SET\s+DEFAULT\s+nextval|ADD\s+GENERATED)`)
+
+	field := func(name string) *Field {
+		for i := range table.Fields {
+			if table.Fields[i].Name == name {
+				return &table.Fields[i]
+			}
+		}
+		return nil
+	}
+
+	if m := rePK.FindStringSubmatch(action); len(m) == 2 {
+		for _, col := range splitIdents(m[1]) {
+			if f := field(col); f != nil {
+				f.PrimaryKey = true
+				f.Nullable = false
+				table.PrimaryKeys = appendIfMissing(table.PrimaryKeys, col)
+			}
+		}
+	}
+	if m := reUnique.FindStringSubmatch(action); len(m) == 3 {
+		ix := Index{Unique: true}
+		if m[1] != "" {
+			ix.Name = unquoteIdent(m[1])
+		}
+		for _, col := range splitIdents(m[2]) {
+			ix.Columns = append(ix.Columns, IndexColumn{Name: col})
+		}
+		table.addIndex(ix)
+	}
+	if m := reFK.FindStringSubmatch(action); len(m) == 2 {
+		if fk, ok := parseForeignKey(m[1]); ok {
+			table.addForeignKey(fk)
+		}
+	}
+	if m := reSerial.FindStringSubmatch(action); len(m) == 2 {
+		if f := field(unquoteIdent(m[1])); f != nil {
+			f.AutoIncrement = true
+		}
+	}
+}
+
+// parsePostgresSequenceOwner handles "ALTER SEQUENCE s OWNED BY t.col",
+// which pg_dump emits for serial columns.
+func parsePostgresSequenceOwner(stmt string, find func(string) *ParsedTable) {
+	reOwned := regexp.MustCompile(`(?is)\bOWNED\s+BY\s+(\S+)`)
+	m := reOwned.FindStringSubmatch(stmt)
+	if len(m) < 2 || strings.EqualFold(m[1], "NONE") {
+		return
+	}
+
+	ref := m[1]
+	dot := strings.LastIndex(ref, ".")
+	if dot < 0 {
+		return
+	}
+	table := find(unquoteIdent(ref[:dot]))
+	if table == nil {
+		return
+	}
+	col := unquoteIdent(ref[dot+1:])
+	for i := range table.Fields {
+		if table.Fields[i].Name == col {
+			table.Fields[i].AutoIncrement = true
+		}
+	}
+}
+
+// parseCopyHeader reads the table and column list of
+// "COPY schema.table (a, b) FROM stdin".
+func parseCopyHeader(stmt string) (string, []string, bool) {
+	reCopy := regexp.MustCompile(`(?is)^COPY\s+(\S+?)\s*(?:\(([^)]*)\))?\s+FROM\s+stdin`)
+	m := reCopy.FindStringSubmatch(stmt)
+	if len(m) < 3 {
+		return "", nil, false
+	}
+	return unquoteIdent(m[1]), splitIdents(m[2]), true
+}
+
+// decodeCopyField undoes the escaping of the COPY text format.
+func decodeCopyField(f string) interface{} {
+	if f == `\N` {
+		return nil
+	}
+	if !strings.Contains(f, `\`) {
+		return f
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(f); i++ {
+		c := f[i]
+		if c != '\\' || i+1 >= len(f) {
+			sb.WriteByte(c)
+			continue
+		}
+		i++
+		switch f[i] {
+		case 'b':
+			sb.WriteByte('\b')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case 'v':
+			sb.WriteByte('\v')
+		case 'x':
+			end := i + 1
+			for end < len(f) && end < i+3 && isHexByte(f[end]) {
+				end++
+			}
+			if n, err := strconv.ParseUint(f[i+1:end], 16, 8); err == nil {
+				sb.WriteByte(byte(n))
+				i = end - 1
+			} else {
+				sb.WriteByte('x')
+			}
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			end := i
+			for end < len(f) && end < i+3 && f[end] >= '0' && f[end] <= '7' {
+				end++
+			}
+			n, _ := strconv.ParseUint(f[i:end], 8, 8)
+			sb.WriteByte(byte(n))
+			i = end - 1
+		default:
+			sb.WriteByte(f[i])
+		}
+	}
+	return sb.String()
+}
+
+func isHexByte(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// copyValue converts COPY text to the value type the MySQL reader would
+// have produced for the column.
+func copyValue(v interface{}, mysqlType string) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		return v
+	}
+
+	t := strings.ToLower(mysqlType)
+	switch {
+	case t == "tinyint(1)":
+		switch s {
+		case "t", "true":
+			return int64(1)
+		case "f", "false":
+			return int64(0)
+		}
+	case t == "int", t == "smallint", t == "bigint":
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+		return Number(s)
+	case strings.HasPrefix(t, "decimal"), t == "float", t == "double":
+		if _, err := strconv.ParseFloat(s, 64); err == nil {
+			return Number(s)
+		}
+	case t == "longblob":
+		if strings.HasPrefix(s, `\x`) {
+			if b, err := hex.DecodeString(s[2:]); err == nil {
+				return b
+			}
+		}
+		return []byte(s)
+	}
+	return s
+}
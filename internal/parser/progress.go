@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// Progress receives progress callbacks from the pipeline: readers report
+// every pass over the input, the bytes they consume and the rows they hand
+// out, connectors report the rows they committed. Unset callbacks are
+// skipped, and a nil *Progress ignores everything.
+type Progress struct {
+	OnPass     func()
+	OnRead     func(n int64)
+	OnParsed   func(table string, rows int)
+	OnImported func(table string, rows int)
+}
+
+type progressKey struct{}
+
+// WithProgress returns a context whose readers and connectors report to p.
+func WithProgress(ctx context.Context, p *Progress) context.Context {
+	return context.WithValue(ctx, progressKey{}, p)
+}
+
+// ProgressFrom returns the Progress attached to ctx, or nil.
+func ProgressFrom(ctx context.Context) *Progress {
+	p, _ := ctx.Value(progressKey{}).(*Progress)
+	return p
+}
+
+func (p *Progress) Pass() {
+	if p != nil && p.OnPass != nil {
+		p.OnPass()
+	}
+}
+
+func (p *Progress) Read(n int64) {
+	if p != nil && p.OnRead != nil && n > 0 {
+		p.OnRead(n)
+	}
+}
+
+func (p *Progress) Parsed(table string, rows int) {
+	if p != nil && p.OnParsed != nil && rows > 0 {
+		p.OnParsed(table, rows)
+	}
+}
+
+func (p *Progress) Imported(table string, rows int) {
+	if p != nil && p.OnImported != nil && rows > 0 {
+		p.OnImported(table, rows)
+	}
+}
+
+// InputSize returns the size on disk of an input: the file itself, or the
+// files of a CSV directory. Bytes reported through Progress.OnRead count
+// towards it.
+func InputSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	if !info.IsDir() {
+		return info.Size()
+	}
+	files, err := os.ReadDir(path)
+	if err != nil {
+		return 0
+	}
+	var size int64
+	for _, f := range files {
+		if fi, err := f.Info(); err == nil && fi.Mode().IsRegular() {
+			size += fi.Size()
+		}
+	}
+	return size
+}
+
+// progressRows reports every batch a RowSource hands out as parsed rows.
+type progressRows struct {
+	RowSource
+}
+
+func (p progressRows) Each(ctx context.Context, fn func(Insert) error) error {
+	progress := ProgressFrom(ctx)
+	progress.Pass()
+	return p.RowSource.Each(ctx, func(ins Insert) error {
+		progress.Parsed(ins.Table, len(ins.Rows))
+		return fn(ins)
+	})
+}
+
+// countingReaderAt reports the bytes read through it, for archives that are
+// read with random access.
+type countingReaderAt struct {
+	r    io.ReaderAt
+	read func(int64)
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(p, off)
+	c.read(int64(n))
+	return n, err
+}
@@ -0,0 +1,509 @@
+package parser
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// ParseSQLiteDumpFile reads the output of the sqlite3 ".dump" command.
+// Column types are normalized to their MySQL spelling like pg_dump input.
+func ParseSQLiteDumpFile(ctx context.Context, filePath string) ([]ParsedTable, error) {
+	file, err := openInput(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ParseSQLiteDump(file)
+}
+
+func ParseSQLiteDump(r io.Reader) ([]ParsedTable, error) {
+	var tables []ParsedTable
+	tableIndex := map[string]int{}
+	find := func(name string) *ParsedTable {
+		if i, ok := tableIndex[name]; ok {
+			return &tables[i]
+		}
+		return nil
+	}
+
+	reader := NewSQLiteStatementReader(r)
+	for {
+		stmt, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		upper := strings.ToUpper(stmt[:min(len(stmt), 32)])
+
+		switch {
+		case strings.HasPrefix(upper, "CREATE TABLE"):
+			table, ok := parseCreateDefinitions(stmt, sqliteToMySQLType)
+			if ok && !isSQLiteInternal(table.TableName) {
+				keepRowidAlias(&table)
+				tableIndex[table.TableName] = len(tables)
+				tables = append(tables, table)
+			}
+
+		case strings.HasPrefix(upper, "CREATE INDEX"), strings.HasPrefix(upper, "CREATE UNIQUE INDEX"):
+			parseCreateIndex(stmt, find)
+
+		case strings.HasPrefix(upper, "INSERT"):
+			ins, err := parseInsert(stmt, true)
+			if err != nil {
+				return nil, fmt.Errorf("insert parse error at line %d: %v", reader.Line(), err)
+			}
+			if t := find(ins.Table); t != nil {
+				t.RowCount += int64(len(ins.Rows))
+			}
+
+		default:
+			logSkippedObject(stmt)
+		}
+	}
+
+	var totalRows int64
+	for _, t := range tables {
+		totalRows += t.RowCount
+	}
+	log.Printf("Parsed %d tables, total %d rows (sqlite dump)", len(tables), totalRows)
+
+	return tables, nil
+}
+
+// SQLiteDumpRows streams the INSERT statements of a sqlite3 ".dump" file.
+type SQLiteDumpRows struct {
+	Path   string
+	Tables []ParsedTable
+}
+
+func (s *SQLiteDumpRows) Each(ctx context.Context, fn func(Insert) error) error {
+	file, err := openInput(ctx, s.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	columns := map[string][]string{}
+	for _, t := range s.Tables {
+		names := make([]string, len(t.Fields))
+		for i, f := range t.Fields {
+			names[i] = f.Name
+		}
+		columns[t.TableName] = names
+	}
+
+	reader := NewSQLiteStatementReader(file)
+	for {
+		stmt, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !isInsertStatement(stmt) {
+			continue
+		}
+
+		ins, err := parseInsert(stmt, true)
+		if err != nil {
+			return fmt.Errorf("insert parse error at line %d: %v", reader.Line(), err)
+		}
+		cols, ok := columns[ins.Table]
+		if !ok {
+			continue
+		}
+		if len(ins.Columns) == 0 {
+			ins.Columns = cols
+		}
+		if err := fn(*ins); err != nil {
+			return err
+		}
+	}
+}
+
+// isSQLiteInternal reports tables SQLite maintains by itself, such as
+// sqlite_sequence for AUTOINCREMENT counters.
+func isSQLiteInternal(name string) bool {
+	return strings.HasPrefix(strings.ToLower(name), "sqlite_")
+}
+
+// keepRowidAlias keeps auto increment only on a single-column primary key,
+// the one case where SQLite assigns values by itself.
+func keepRowidAlias(t *ParsedTable) {
+	for i := range t.Fields {
+		f := &t.Fields[i]
+		f.AutoIncrement = f.AutoIncrement && f.PrimaryKey && len(t.PrimaryKeys) == 1
+	}
+}
+
+// sqliteToMySQLType spells a declared SQLite column type the way a MySQL
+// dump would, following SQLite's type affinity rules for unknown names. An
+// INTEGER PRIMARY KEY is a rowid alias, so INTEGER reports auto increment
+// and the caller keeps it only for primary keys.
+func sqliteToMySQLType(sqliteType string) (string, bool) {
+	t := strings.ToLower(strings.TrimSpace(sqliteType))
+
+	args := ""
+	if start := strings.Index(t, "("); start >= 0 {
+		if end := strings.Index(t[start:], ")"); end >= 0 {
+			args = strings.ReplaceAll(t[start:start+end+1], " ", "")
+		}
+	}
+	base := t
+	if i := strings.IndexAny(base, "( "); i >= 0 {
+		base = base[:i]
+	}
+	if strings.HasPrefix(t, "character varying") || strings.HasPrefix(t, "varying character") {
+		base = "varchar"
+	}
+
+	switch base {
+	case "":
+		return "longtext", false
+	case "integer":
+		return "bigint", true
+	case "tinyint", "smallint", "mediumint", "int", "bigint":
+		return base + args + typeSuffix(t), false
+	case "boolean", "bool":
+		return "tinyint(1)", false
+	case "decimal", "numeric":
+		if args == "" {
+			return "decimal(65,30)", false
+		}
+		return "decimal" + args, false
+	case "date", "datetime", "time", "json":
+		return base, false
+	case "timestamp":
+		return "datetime", false
+	case "varchar", "nvarchar", "character", "nchar", "char":
+		if args == "" {
+			return "longtext", false
+		}
+		if base == "char" || base == "nchar" || base == "character" {
+			return "char" + args, false
+		}
+		return "varchar" + args, false
+	}
+
+	switch {
+	case strings.Contains(t, "int"):
+		return "bigint", false
+	case strings.Contains(t, "char"), strings.Contains(t, "clob"), strings.Contains(t, "text"):
+		return "longtext", false
+	case strings.Contains(t, "blob"):
+		return "longblob", false
+	case strings.Contains(t, "real"), strings.Contains(t, "floa"), strings.Contains(t, "doub"):
+		return "double", false
+	}
+	return "decimal(65,30)", false
+}
+
+func typeSuffix(t string) string {
+	if strings.Contains(t, "unsigned") {
+		return " unsigned"
+	}
+	return ""
+}
+
+// ParseSQLiteDBFile reads the schema of a SQLite database file directly from
+// sqlite_master and the table pragmas.
+func ParseSQLiteDBFile(ctx context.Context, filePath string) ([]ParsedTable, error) {
+	conn, err := openSQLiteFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(ctx, `SELECT name, sql FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY rowid`)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite_master read error: %v", err)
+	}
+	var tables []ParsedTable
+	for rows.Next() {
+		var name string
+		var ddl sql.NullString
+		if err := rows.Scan(&name, &ddl); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		// The stored CREATE statement carries what the pragmas do not, such as
+		// AUTOINCREMENT and column defaults in their original spelling.
+		table, ok := parseCreateDefinitions(ddl.String, sqliteToMySQLType)
+		if !ok {
+			table = ParsedTable{TableName: name}
+		}
+		table.TableName = name
+		tables = append(tables, table)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	find := func(name string) *ParsedTable {
+		for i := range tables {
+			if tables[i].TableName == name {
+				return &tables[i]
+			}
+		}
+		return nil
+	}
+
+	for i := range tables {
+		t := &tables[i]
+		if err := readSQLiteColumns(ctx, conn, t); err != nil {
+			return nil, err
+		}
+		if err := readSQLiteIndexes(ctx, conn, t, find); err != nil {
+			return nil, err
+		}
+		if err := conn.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s", quoteSQLiteName(t.TableName))).Scan(&t.RowCount); err != nil {
+			return nil, fmt.Errorf("%s row count error: %v", t.TableName, err)
+		}
+	}
+
+	var totalRows int64
+	for _, t := range tables {
+		totalRows += t.RowCount
+	}
+	log.Printf("Parsed %d tables, total %d rows (sqlite file)", len(tables), totalRows)
+
+	return tables, nil
+}
+
+func openSQLiteFile(filePath string) (*sql.DB, error) {
+	conn, err := sql.Open("sqlite", "file:"+filePath+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("sqlite open error: %v", err)
+	}
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("sqlite open error: %v", err)
+	}
+	return conn, nil
+}
+
+func quoteSQLiteName(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// readSQLiteColumns fills in the columns from PRAGMA table_info, which also
+// covers tables whose CREATE statement could not be parsed, and the foreign
+// keys from PRAGMA foreign_key_list.
+func readSQLiteColumns(ctx context.Context, conn *sql.DB, t *ParsedTable) error {
+	parsed := map[string]Field{}
+	for _, f := range t.Fields {
+		parsed[f.Name] = f
+	}
+
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", quoteSQLiteName(t.TableName)))
+	if err != nil {
+		return fmt.Errorf("%s table_info error: %v", t.TableName, err)
+	}
+	var fields []Field
+	pkOrder := map[string]int{}
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var def sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &def, &pk); err != nil {
+			rows.Close()
+			return err
+		}
+
+		mysqlType, rowid := sqliteToMySQLType(colType)
+		field, ok := parsed[name]
+		if !ok {
+			field = Field{Name: name, Type: mysqlType, Default: strings.Trim(def.String, "'")}
+		}
+		field.Type = mysqlType
+		field.Nullable = notNull == 0 && pk == 0
+		field.PrimaryKey = pk > 0
+		field.AutoIncrement = field.AutoIncrement || rowid
+		if pk > 0 {
+			pkOrder[name] = pk
+		}
+		fields = append(fields, field)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// table_info numbers key columns by their position in the key.
+	t.PrimaryKeys = make([]string, len(pkOrder))
+	for name, pos := range pkOrder {
+		t.PrimaryKeys[pos-1] = name
+	}
+	t.Fields = fields
+	keepRowidAlias(t)
+
+	fkRows, err := conn.QueryContext(ctx, fmt.Sprintf("PRAGMA foreign_key_list(%s)", quoteSQLiteName(t.TableName)))
+	if err != nil {
+		return fmt.Errorf("%s foreign_key_list error: %v", t.TableName, err)
+	}
+	defer fkRows.Close()
+	// Keys the CREATE statement already gave keep their names and
+	// deferrability; the pragma adds those it could not be read from.
+	fks := map[int]*ForeignKey{}
+	var ids []int
+	for fkRows.Next() {
+		var id, seq int
+		var table, from, onUpdate, onDelete, match string
+		var to sql.NullString
+		if err := fkRows.Scan(&id, &seq, &table, &from, &to, &onUpdate, &onDelete, &match); err != nil {
+			return err
+		}
+		fk, ok := fks[id]
+		if !ok {
+			fk = &ForeignKey{ReferencedTable: table, OnDelete: onDelete, OnUpdate: onUpdate}
+			fks[id] = fk
+			ids = append(ids, id)
+		}
+		fk.Columns = append(fk.Columns, from)
+		fk.ReferencedColumns = append(fk.ReferencedColumns, to.String)
+	}
+	for _, id := range ids {
+		fk := fks[id]
+		implicit := false
+		for _, c := range fk.ReferencedColumns {
+			implicit = implicit || c == ""
+		}
+		// A key without referenced columns points at a primary key this
+		// model does not resolve.
+		if implicit {
+			continue
+		}
+		if fk.OnDelete == "NO ACTION" {
+			fk.OnDelete = ""
+		}
+		if fk.OnUpdate == "NO ACTION" {
+			fk.OnUpdate = ""
+		}
+		t.addForeignKey(*fk)
+	}
+	return fkRows.Err()
+}
+
+// readSQLiteIndexes marks indexed columns from CREATE INDEX statements kept
+// in sqlite_master; automatic indexes of UNIQUE constraints come from the
+// table definition itself.
+func readSQLiteIndexes(ctx context.Context, conn *sql.DB, t *ParsedTable, find func(string) *ParsedTable) error {
+	rows, err := conn.QueryContext(ctx, `SELECT sql FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND sql IS NOT NULL`, t.TableName)
+	if err != nil {
+		return fmt.Errorf("%s index list error: %v", t.TableName, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ddl string
+		if err := rows.Scan(&ddl); err != nil {
+			return err
+		}
+		parseCreateIndex(ddl, find)
+	}
+	return rows.Err()
+}
+
+// SQLiteFileRows streams the tables of a SQLite database file, converting
+// values to the Go types the dump readers produce.
+type SQLiteFileRows struct {
+	Path   string
+	Tables []ParsedTable
+}
+
+func (s *SQLiteFileRows) Each(ctx context.Context, fn func(Insert) error) error {
+	conn, err := openSQLiteFile(s.Path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for _, t := range s.Tables {
+		if err := s.eachTable(ctx, conn, t, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteFileRows) eachTable(ctx context.Context, conn *sql.DB, t ParsedTable, fn func(Insert) error) error {
+	cols := make([]string, len(t.Fields))
+	quoted := make([]string, len(t.Fields))
+	types := make([]string, len(t.Fields))
+	for i, f := range t.Fields {
+		cols[i] = f.Name
+		quoted[i] = quoteSQLiteName(f.Name)
+		types[i] = f.Type
+	}
+	if len(cols) == 0 {
+		return nil
+	}
+
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s", strings.Join(quoted, ", "), quoteSQLiteName(t.TableName)))
+	if err != nil {
+		return fmt.Errorf("%s read error: %v", t.TableName, err)
+	}
+	defer rows.Close()
+
+	batch := Insert{Table: t.TableName, Columns: cols}
+	dest := make([]interface{}, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range dest {
+		ptrs[i] = &dest[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		row := make([]interface{}, len(cols))
+		for i, v := range dest {
+			row[i] = sqliteFileValue(v, types[i])
+		}
+		batch.Rows = append(batch.Rows, row)
+		if len(batch.Rows) >= copyBatchRows {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			batch.Rows = nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(batch.Rows) > 0 {
+		return fn(batch)
+	}
+	return nil
+}
+
+func sqliteFileValue(v interface{}, mysqlType string) interface{} {
+	switch val := v.(type) {
+	case float64:
+		return Number(strconv.FormatFloat(val, 'g', -1, 64))
+	case time.Time:
+		if mysqlType == "date" {
+			return val.Format("2006-01-02")
+		}
+		return val.Format("2006-01-02 15:04:05")
+	case []byte:
+		if !strings.Contains(mysqlType, "blob") {
+			return string(val)
+		}
+		return val
+	default:
+		return v
+	}
+}
@@ -0,0 +1,507 @@
+package parser
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Number keeps the literal text of a numeric value that does not fit an
+// int64, so DECIMAL values reach the target without float rounding.
+type Number string
+
+// Insert is one decoded INSERT statement. Every row holds one value per
+// column: nil, int64, Number, string, []byte or bool.
+type Insert struct {
+	Table   string
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// RowSource replays the data of a parsed input in file order, one INSERT
+// batch at a time, so rows never have to be held in memory all together.
+type RowSource interface {
+	Each(ctx context.Context, fn func(Insert) error) error
+}
+
+// DumpRows streams the INSERT statements of a SQL dump on disk. Column lists
+// missing from the statements are resolved from Tables.
+type DumpRows struct {
+	Path   string
+	Tables []ParsedTable
+}
+
+func (d *DumpRows) Each(ctx context.Context, fn func(Insert) error) error {
+	file, err := openInput(ctx, d.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	columns := map[string][]string{}
+	for _, t := range d.Tables {
+		names := make([]string, len(t.Fields))
+		for i, f := range t.Fields {
+			names[i] = f.Name
+		}
+		columns[t.TableName] = names
+	}
+
+	reader := NewStatementReader(file)
+	for {
+		stmt, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !isInsertStatement(stmt) {
+			continue
+		}
+
+		ins, err := ParseInsert(stmt)
+		if err != nil {
+			return fmt.Errorf("insert parse error at line %d: %v", reader.Line(), err)
+		}
+		cols, ok := columns[ins.Table]
+		if !ok {
+			continue
+		}
+		if len(ins.Columns) == 0 {
+			ins.Columns = cols
+		}
+		if err := fn(*ins); err != nil {
+			return err
+		}
+	}
+}
+
+func isInsertStatement(stmt string) bool {
+	upper := strings.ToUpper(stmt[:min(len(stmt), 8)])
+	return strings.HasPrefix(upper, "INSERT") || strings.HasPrefix(upper, "REPLACE")
+}
+
+// ParseInsert decodes a (multi-row) MySQL INSERT statement into typed rows.
+func ParseInsert(stmt string) (*Insert, error) {
+	return parseInsert(stmt, false)
+}
+
+// parseInsert also serves pg_dump --inserts output, where string literals
+// follow standard_conforming_strings and values may carry ::type casts.
+func parseInsert(stmt string, standardStrings bool) (*Insert, error) {
+	l := &valueLexer{src: stmt, standardStrings: standardStrings}
+
+	if !l.keyword("INSERT") && !l.keyword("REPLACE") {
+		return nil, fmt.Errorf("not an INSERT statement")
+	}
+	for l.keyword("LOW_PRIORITY") || l.keyword("DELAYED") || l.keyword("HIGH_PRIORITY") || l.keyword("IGNORE") {
+		// modifiers do not change how rows are decoded
+	}
+	if !l.keyword("INTO") {
+		return nil, fmt.Errorf("INTO expected at offset %d", l.pos)
+	}
+
+	ins := &Insert{}
+	name, err := l.identifier()
+	if err != nil {
+		return nil, err
+	}
+	for l.peek() == '.' {
+		l.pos++
+		if name, err = l.identifier(); err != nil {
+			return nil, err
+		}
+	}
+	ins.Table = name
+
+	if l.peek() == '(' {
+		l.pos++
+		for {
+			col, err := l.identifier()
+			if err != nil {
+				return nil, err
+			}
+			ins.Columns = append(ins.Columns, col)
+			if l.peek() == ',' {
+				l.pos++
+				continue
+			}
+			if l.peek() != ')' {
+				return nil, fmt.Errorf("unterminated column list in %s", ins.Table)
+			}
+			l.pos++
+			break
+		}
+	}
+
+	if !l.keyword("VALUES") && !l.keyword("VALUE") {
+		return nil, fmt.Errorf("VALUES expected for %s at offset %d", ins.Table, l.pos)
+	}
+
+	for {
+		if l.peek() != '(' {
+			return nil, fmt.Errorf("row expected for %s at offset %d", ins.Table, l.pos)
+		}
+		l.pos++
+
+		var row []interface{}
+		for {
+			v, err := l.value()
+			if err != nil {
+				return nil, fmt.Errorf("%s row %d: %v", ins.Table, len(ins.Rows)+1, err)
+			}
+			row = append(row, v)
+			l.skipCast()
+			if l.peek() == ',' {
+				l.pos++
+				continue
+			}
+			if l.peek() != ')' {
+				return nil, fmt.Errorf("%s row %d: ',' or ')' expected at offset %d", ins.Table, len(ins.Rows)+1, l.pos)
+			}
+			l.pos++
+			break
+		}
+		ins.Rows = append(ins.Rows, row)
+
+		if l.peek() != ',' {
+			break
+		}
+		l.pos++
+	}
+
+	return ins, nil
+}
+
+type valueLexer struct {
+	src             string
+	pos             int
+	standardStrings bool
+}
+
+// skipCast drops a PostgreSQL "::type" suffix after a value.
+func (l *valueLexer) skipCast() {
+	for l.peek() == ':' && l.pos+1 < len(l.src) && l.src[l.pos+1] == ':' {
+		l.pos += 2
+		for l.word() != "" {
+			l.pos += len(l.word())
+		}
+		if l.peek() == '(' {
+			if end := strings.IndexByte(l.src[l.pos:], ')'); end >= 0 {
+				l.pos += end + 1
+			}
+		}
+		if strings.HasPrefix(l.src[l.pos:], "[]") {
+			l.pos += 2
+		}
+	}
+}
+
+func (l *valueLexer) skipSpace() {
+	for l.pos < len(l.src) {
+		switch l.src[l.pos] {
+		case ' ', '\t', '\n', '\r':
+			l.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (l *valueLexer) peek() byte {
+	l.skipSpace()
+	if l.pos >= len(l.src) {
+		return 0
+	}
+	return l.src[l.pos]
+}
+
+func (l *valueLexer) word() string {
+	l.skipSpace()
+	end := l.pos
+	for end < len(l.src) && isWordByte(l.src[end]) {
+		end++
+	}
+	return l.src[l.pos:end]
+}
+
+func (l *valueLexer) keyword(kw string) bool {
+	if w := l.word(); strings.EqualFold(w, kw) {
+		l.pos += len(w)
+		return true
+	}
+	return false
+}
+
+func (l *valueLexer) identifier() (string, error) {
+	if l.peek() == '`' || l.peek() == '"' {
+		q := l.src[l.pos]
+		var sb strings.Builder
+		for i := l.pos + 1; i < len(l.src); i++ {
+			if l.src[i] != q {
+				sb.WriteByte(l.src[i])
+				continue
+			}
+			if i+1 < len(l.src) && l.src[i+1] == q {
+				sb.WriteByte(q)
+				i++
+				continue
+			}
+			l.pos = i + 1
+			return sb.String(), nil
+		}
+		return "", fmt.Errorf("unterminated identifier at offset %d", l.pos)
+	}
+	w := l.word()
+	if w == "" {
+		return "", fmt.Errorf("identifier expected at offset %d", l.pos)
+	}
+	l.pos += len(w)
+	return w, nil
+}
+
+func (l *valueLexer) value() (interface{}, error) {
+	c := l.peek()
+	switch {
+	case c == '\'' || (c == '"' && !l.standardStrings):
+		return l.quoted(!l.standardStrings)
+
+	case c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9'):
+		return l.number()
+
+	case isWordByte(c):
+		w := l.word()
+		upper := strings.ToUpper(w)
+		switch {
+		case upper == "NULL":
+			l.pos += len(w)
+			return nil, nil
+		case upper == "TRUE":
+			l.pos += len(w)
+			return true, nil
+		case upper == "FALSE":
+			l.pos += len(w)
+			return false, nil
+		case upper == "E" && l.pos+1 < len(l.src) && l.src[l.pos+1] == '\'':
+			l.pos++
+			return l.quoted(true)
+		case (upper == "X" || upper == "B") && l.pos+1 < len(l.src) && l.src[l.pos+1] == '\'':
+			l.pos++
+			s, err := l.quoted(!l.standardStrings)
+			if err != nil {
+				return nil, err
+			}
+			if upper == "X" {
+				return hex.DecodeString(s.(string))
+			}
+			return parseBits(s.(string))
+		case strings.HasPrefix(w, "_"):
+			// Charset introducer such as _binary'..' or _utf8mb4'..'
+			l.pos += len(w)
+			v, err := l.value()
+			if s, ok := v.(string); ok && upper == "_BINARY" {
+				return []byte(s), err
+			}
+			return v, err
+		case (upper == "CHAR" || upper == "REPLACE" || upper == "UNISTR") && l.pos+len(w) < len(l.src) && l.src[l.pos+len(w)] == '(':
+			// sqlite3 .dump spells control characters as
+			// replace('a\nb','\n',char(10)) or unistr('a\u000ab').
+			l.pos += len(w)
+			return l.call(upper)
+		}
+		return nil, fmt.Errorf("unsupported value %q at offset %d", w, l.pos)
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", c, l.pos)
+}
+
+// call evaluates the char(), replace() and unistr() functions found in
+// SQLite dumps.
+func (l *valueLexer) call(name string) (interface{}, error) {
+	l.pos++
+	var args []interface{}
+	for l.peek() != ')' {
+		v, err := l.value()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+		if l.peek() == ',' {
+			l.pos++
+		}
+	}
+	l.pos++
+
+	switch name {
+	case "CHAR":
+		var sb strings.Builder
+		for _, a := range args {
+			if n, ok := a.(int64); ok {
+				sb.WriteRune(rune(n))
+			}
+		}
+		return sb.String(), nil
+	case "UNISTR":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("unistr() takes 1 argument, got %d", len(args))
+		}
+		str, _ := args[0].(string)
+		return unistr(str), nil
+	default:
+		if len(args) != 3 {
+			return nil, fmt.Errorf("replace() takes 3 arguments, got %d", len(args))
+		}
+		str, _ := args[0].(string)
+		from, _ := args[1].(string)
+		to, _ := args[2].(string)
+		if from == "" {
+			return str, nil
+		}
+		return strings.ReplaceAll(str, from, to), nil
+	}
+}
+
+// unistr decodes the \XXXX, \uXXXX, \+XXXXXX and \UXXXXXXXX escapes of
+// SQLite's unistr().
+func unistr(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+		digits, skip := 4, 1
+		switch s[i+1] {
+		case '\\':
+			sb.WriteByte('\\')
+			i++
+			continue
+		case 'u':
+			skip = 2
+		case '+':
+			digits, skip = 6, 2
+		case 'U':
+			digits, skip = 8, 2
+		default:
+			skip = 1
+		}
+		end := i + skip + digits
+		if end > len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+		n, err := strconv.ParseUint(s[i+skip:end], 16, 32)
+		if err != nil {
+			sb.WriteByte(s[i])
+			continue
+		}
+		sb.WriteRune(rune(n))
+		i = end - 1
+	}
+	return sb.String()
+}
+
+// quoted decodes a string literal, including MySQL backslash escapes when
+// escapes is set.
+func (l *valueLexer) quoted(escapes bool) (interface{}, error) {
+	q := l.src[l.pos]
+	start := l.pos
+	var sb strings.Builder
+	for i := l.pos + 1; i < len(l.src); i++ {
+		c := l.src[i]
+		switch {
+		case c == '\\' && escapes && i+1 < len(l.src):
+			i++
+			switch l.src[i] {
+			case '0':
+				sb.WriteByte(0)
+			case 'b':
+				sb.WriteByte('\b')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case 'Z':
+				sb.WriteByte(0x1a)
+			case '%', '_':
+				sb.WriteByte('\\')
+				sb.WriteByte(l.src[i])
+			default:
+				sb.WriteByte(l.src[i])
+			}
+		case c == q && i+1 < len(l.src) && l.src[i+1] == q:
+			sb.WriteByte(q)
+			i++
+		case c == q:
+			l.pos = i + 1
+			return sb.String(), nil
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return nil, fmt.Errorf("unterminated string at offset %d", start)
+}
+
+func (l *valueLexer) number() (interface{}, error) {
+	start := l.pos
+	end := l.pos
+	if l.src[end] == '-' || l.src[end] == '+' {
+		end++
+	}
+
+	if end+1 < len(l.src) && l.src[end] == '0' && (l.src[end+1] == 'x' || l.src[end+1] == 'b') {
+		digitsStart := end + 2
+		end = digitsStart
+		for end < len(l.src) && isWordByte(l.src[end]) {
+			end++
+		}
+		l.pos = end
+		if l.src[digitsStart-1] == 'x' {
+			return hex.DecodeString(l.src[digitsStart:end])
+		}
+		return parseBits(l.src[digitsStart:end])
+	}
+
+	for end < len(l.src) {
+		c := l.src[end]
+		if (c >= '0' && c <= '9') || c == '.' || c == 'e' || c == 'E' ||
+			((c == '-' || c == '+') && (l.src[end-1] == 'e' || l.src[end-1] == 'E')) {
+			end++
+			continue
+		}
+		break
+	}
+	l.pos = end
+
+	text := l.src[start:end]
+	if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return n, nil
+	}
+	if _, err := strconv.ParseFloat(text, 64); err != nil {
+		return nil, fmt.Errorf("invalid number %q", text)
+	}
+	return Number(text), nil
+}
+
+func parseBits(s string) (interface{}, error) {
+	if s == "" {
+		return int64(0), nil
+	}
+	n, err := strconv.ParseUint(s, 2, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bit literal %q", s)
+	}
+	return int64(n), nil
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c >= 0x80
+}
@@ -0,0 +1,193 @@
+package parser
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseInsert(t *testing.T) {
+	tests := []struct {
+		name string
+		stmt string
+		want *Insert
+	}{
+		{
+			name: "columns and rows",
+			stmt: "INSERT INTO `t` (`id`,`name`) VALUES (1,'a'),(2,NULL)",
+			want: &Insert{Table: "t", Columns: []string{"id", "name"}, Rows: [][]interface{}{{int64(1), "a"}, {int64(2), nil}}},
+		},
+		{
+			name: "schema and modifiers",
+			stmt: "INSERT IGNORE INTO db.`t` VALUES (1)",
+			want: &Insert{Table: "t", Rows: [][]interface{}{{int64(1)}}},
+		},
+		{
+			name: "replace",
+			stmt: "REPLACE INTO t VALUE (TRUE,false)",
+			want: &Insert{Table: "t", Rows: [][]interface{}{{true, false}}},
+		},
+		{
+			name: "quoted identifiers",
+			stmt: "INSERT INTO `a``b` (\"c\"\"d\") VALUES (1)",
+			want: &Insert{Table: "a`b", Columns: []string{`c"d`}, Rows: [][]interface{}{{int64(1)}}},
+		},
+		{
+			name: "escapes",
+			stmt: `INSERT INTO t VALUES ('it\'s','a\\b','\0\b\n\r\t\Z','\%\_','\q','x''y',"d\"q")`,
+			want: &Insert{Table: "t", Rows: [][]interface{}{{"it's", `a\b`, "\x00\b\n\r\t\x1a", `\%\_`, "q", "x'y", `d"q`}}},
+		},
+		{
+			name: "numbers",
+			stmt: "INSERT INTO t VALUES (-5,+7,3.14,-1e3,.5,99999999999999999999)",
+			want: &Insert{Table: "t", Rows: [][]interface{}{{int64(-5), int64(7), Number("3.14"), Number("-1e3"), Number(".5"), Number("99999999999999999999")}}},
+		},
+		{
+			name: "hex literals",
+			stmt: "INSERT INTO t VALUES (X'0aFF',x'',0xCAFE)",
+			want: &Insert{Table: "t", Rows: [][]interface{}{{[]byte{0x0a, 0xff}, []byte{}, []byte{0xca, 0xfe}}}},
+		},
+		{
+			name: "bit literals",
+			stmt: "INSERT INTO t VALUES (b'101',B'',0b11)",
+			want: &Insert{Table: "t", Rows: [][]interface{}{{int64(5), int64(0), int64(3)}}},
+		},
+		{
+			name: "charset introducers",
+			stmt: "INSERT INTO t VALUES (_binary 'a\\0',_binary 0x01,_utf8mb4'x')",
+			want: &Insert{Table: "t", Rows: [][]interface{}{{[]byte("a\x00"), []byte{0x01}, "x"}}},
+		},
+		{
+			name: "whitespace",
+			stmt: "INSERT INTO t\n(a, b)\nVALUES\n( 1 , 'x' ) ,\n\t( 2 , 'y' )",
+			want: &Insert{Table: "t", Columns: []string{"a", "b"}, Rows: [][]interface{}{{int64(1), "x"}, {int64(2), "y"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseInsert(tt.stmt)
+			if err != nil {
+				t.Fatalf("ParseInsert: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseInsertStandardStrings covers the pg_dump --inserts and sqlite3
+// .dump spellings.
+func TestParseInsertStandardStrings(t *testing.T) {
+	tests := []struct {
+		name string
+		stmt string
+		want []interface{}
+	}{
+		{"backslash", `INSERT INTO t VALUES ('a\b')`, []interface{}{`a\b`}},
+		{"escape string", `INSERT INTO t VALUES (E'a\nb\'c')`, []interface{}{"a\nb'c"}},
+		{"casts", "INSERT INTO public.t VALUES ('{1,2}'::integer[], 'x'::character varying(10), 1::bigint)", []interface{}{"{1,2}", "x", int64(1)}},
+		{"bytea", `INSERT INTO t VALUES ('\x0aff'::bytea)`, []interface{}{`\x0aff`}},
+		{"blob", "INSERT INTO t VALUES(X'00ff')", []interface{}{[]byte{0x00, 0xff}}},
+		{"replace", `INSERT INTO t VALUES(replace('a\nb','\n',char(10)))`, []interface{}{"a\nb"}},
+		{"char", "INSERT INTO t VALUES(char(104,105))", []interface{}{"hi"}},
+		{"unistr", `INSERT INTO t VALUES(unistr('a\000abç\\'))`, []interface{}{"a\nbç\\"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseInsert(tt.stmt, true)
+			if err != nil {
+				t.Fatalf("parseInsert: %v", err)
+			}
+			if len(got.Rows) != 1 || !reflect.DeepEqual(got.Rows[0], tt.want) {
+				t.Errorf("got %#v, want %#v", got.Rows, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInsertErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		stmt string
+		want string
+	}{
+		{"not an insert", "UPDATE t SET a = 1", "not an INSERT statement"},
+		{"no INTO", "INSERT t VALUES (1)", "INTO expected at offset 7"},
+		{"no VALUES", "INSERT INTO t SELECT 1", "VALUES expected for t at offset 14"},
+		{"column list", "INSERT INTO t (a VALUES (1)", "unterminated column list in t"},
+		{"unterminated string", "INSERT INTO t VALUES (1,'a)", "t row 1: unterminated string at offset 24"},
+		{"missing comma", "INSERT INTO t VALUES (1),(2 3)", "t row 2: ',' or ')' expected at offset 28"},
+		{"function", "INSERT INTO t VALUES (NOW())", `t row 1: unsupported value "NOW" at offset 22`},
+		{"hex digits", "INSERT INTO t VALUES (X'0g')", "t row 1: encoding/hex: invalid byte: U+0067 'g'"},
+		{"bit digits", "INSERT INTO t VALUES (b'12')", `t row 1: invalid bit literal "12"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseInsert(tt.stmt)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDumpRows(t *testing.T) {
+	tables := []ParsedTable{{TableName: "t", Fields: []Field{{Name: "id"}, {Name: "name"}}}}
+
+	tests := []struct {
+		name    string
+		dump    string
+		want    []Insert
+		wantErr string
+	}{
+		{
+			name: "columns from the schema",
+			dump: "CREATE TABLE t (id int, name text);\n" +
+				"INSERT INTO t VALUES (1,'a;b');\n" +
+				"INSERT INTO other VALUES (2);\n" +
+				"INSERT INTO t (name, id) VALUES ('c', 3);\n",
+			want: []Insert{
+				{Table: "t", Columns: []string{"id", "name"}, Rows: [][]interface{}{{int64(1), "a;b"}}},
+				{Table: "t", Columns: []string{"name", "id"}, Rows: [][]interface{}{{"c", int64(3)}}},
+			},
+		},
+		{
+			name: "parse error",
+			dump: "CREATE TABLE t (id int, name text);\n\n" +
+				"INSERT INTO t VALUES (1,'a'),(2 'b');\n",
+			want:    []Insert{},
+			wantErr: "insert parse error at line 3: t row 2: ',' or ')' expected at offset 32",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "dump.sql")
+			if err := os.WriteFile(path, []byte(tt.dump), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got := []Insert{}
+			err := (&DumpRows{Path: path, Tables: tables}).Each(context.Background(), func(ins Insert) error {
+				got = append(got, ins)
+				return nil
+			})
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("Each: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package worker
+
+import (
+	"sync"
+	"time"
+
+	"bigdataimporter/internal/parser"
+)
+
+// Event types sent to subscribers of a job.
+const (
+	EventState    = "state"
+	EventProgress = "progress"
+)
+
+// progressInterval throttles progress events; stage changes are sent at once.
+const progressInterval = 500 * time.Millisecond
+
+// Event is one message for the subscribers of a job: a JobStatus for a
+// stage change, a JobProgress for progress.
+type Event struct {
+	Type string
+	Data interface{}
+}
+
+type TableProgress struct {
+	Name         string `json:"name"`
+	Rows         int64  `json:"rows"`
+	RowsParsed   int64  `json:"rows_parsed"`
+	RowsImported int64  `json:"rows_imported"`
+}
+
+// JobProgress is a snapshot of a running job. Bytes and parsed rows count
+// the current pass over the input; the schema pass and the data pass each
+// read it once. Throughput is measured over the current stage, and the ETA
+// is based on imported rows while importing and on bytes before that.
+type JobProgress struct {
+	ID           string          `json:"id"`
+	State        string          `json:"state"`
+	BytesRead    int64           `json:"bytes_read"`
+	BytesTotal   int64           `json:"bytes_total"`
+	RowsTotal    int64           `json:"rows_total"`
+	RowsParsed   int64           `json:"rows_parsed"`
+	RowsImported int64           `json:"rows_imported"`
+	Tables       []TableProgress `json:"tables,omitempty"`
+	RowsPerSec   float64         `json:"rows_per_sec"`
+	BytesPerSec  float64         `json:"bytes_per_sec"`
+	ETASeconds   *int64          `json:"eta_seconds"`
+	Elapsed      float64         `json:"elapsed_seconds"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+}
+
+// tracker turns the pipeline's progress callbacks into JobProgress events.
+type tracker struct {
+	mu         sync.Mutex
+	p          JobProgress
+	index      map[string]int
+	stageStart time.Time
+	stageBytes int64
+	stageRows  int64
+	published  time.Time
+	publish    func(Event)
+}
+
+func newTracker(id string, bytesTotal int64, publish func(Event)) *tracker {
+	return &tracker{
+		p:          JobProgress{ID: id, State: StateQueued, BytesTotal: bytesTotal},
+		index:      map[string]int{},
+		stageStart: time.Now(),
+		publish:    publish,
+	}
+}
+
+// callbacks returns the parser.Progress feeding the tracker.
+func (t *tracker) callbacks() *parser.Progress {
+	return &parser.Progress{
+		OnPass: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.p.BytesRead, t.p.RowsParsed, t.stageBytes = 0, 0, 0
+			for i := range t.p.Tables {
+				t.p.Tables[i].RowsParsed = 0
+			}
+		},
+		OnRead: func(n int64) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.p.BytesRead += n
+			t.stageBytes += n
+			t.changed(false)
+		},
+		OnParsed: func(table string, rows int) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.table(table).RowsParsed += int64(rows)
+			t.p.RowsParsed += int64(rows)
+			t.changed(false)
+		},
+		OnImported: func(table string, rows int) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.table(table).RowsImported += int64(rows)
+			t.p.RowsImported += int64(rows)
+			t.stageRows += int64(rows)
+			t.changed(false)
+		},
+	}
+}
+
+func (t *tracker) table(name string) *TableProgress {
+	i, ok := t.index[name]
+	if !ok {
+		i = len(t.p.Tables)
+		t.index[name] = i
+		t.p.Tables = append(t.p.Tables, TableProgress{Name: name})
+	}
+	return &t.p.Tables[i]
+}
+
+// setTables records the row counts found by the schema pass.
+func (t *tracker) setTables(tables []TableCount) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.p.RowsTotal = 0
+	for _, tc := range tables {
+		t.table(tc.Name).Rows = tc.Rows
+		t.p.RowsTotal += tc.Rows
+	}
+	t.changed(true)
+}
+
+func (t *tracker) setStage(state string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.p.State = state
+	// A final state keeps the throughput of the last stage.
+	if !isFinal(state) {
+		t.stageStart = time.Now()
+		t.stageBytes, t.stageRows = 0, 0
+	}
+	t.changed(true)
+}
+
+// changed publishes a snapshot when forced or when the last one is older
+// than progressInterval. Callers hold t.mu.
+func (t *tracker) changed(force bool) {
+	now := time.Now()
+	if !force && now.Sub(t.published) < progressInterval {
+		return
+	}
+	t.published = now
+	t.publish(Event{Type: EventProgress, Data: t.snapshotLocked(now)})
+}
+
+func (t *tracker) snapshot() JobProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.snapshotLocked(time.Now())
+}
+
+func (t *tracker) snapshotLocked(now time.Time) JobProgress {
+	p := t.p
+	p.Tables = append([]TableProgress(nil), t.p.Tables...)
+	p.UpdatedAt = now
+
+	elapsed := now.Sub(t.stageStart).Seconds()
+	p.Elapsed = elapsed
+	if elapsed <= 0 {
+		return p
+	}
+	p.BytesPerSec = float64(t.stageBytes) / elapsed
+	p.RowsPerSec = float64(t.stageRows) / elapsed
+
+	var eta float64 = -1
+	switch {
+	case p.State == StateImporting && p.RowsTotal > 0 && p.RowsPerSec > 0:
+		eta = float64(p.RowsTotal-p.RowsImported) / p.RowsPerSec
+	case !isFinal(p.State) && p.BytesTotal > 0 && p.BytesPerSec > 0:
+		eta = float64(p.BytesTotal-p.BytesRead) / p.BytesPerSec
+	}
+	if eta >= 0 {
+		s := int64(eta + 0.5)
+		p.ETASeconds = &s
+	}
+	return p
+}
@@ -0,0 +1,367 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"bigdataimporter/internal/db"
+)
+
+// Job states, in the order a successful job passes through them.
+const (
+	StateQueued     = "queued"
+	StateParsing    = "parsing"
+	StateGenerating = "generating"
+	StateImporting  = "importing"
+	StateDone       = "done"
+	StateFailed     = "failed"
+	StateCancelled  = "cancelled"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobFinished = errors.New("job already finished")
+)
+
+type TableCount struct {
+	Name string `json:"name"`
+	Rows int64  `json:"rows"`
+}
+
+// JobStatus is what the registry knows about a job: where it is in the
+// pipeline, what it read and what it produced.
+type JobStatus struct {
+	ID         string             `json:"id"`
+	FilePath   string             `json:"file_path"`
+	Source     string             `json:"source,omitempty"`
+	Target     string             `json:"target"`
+	DryRun     bool               `json:"dry_run,omitempty"`
+	IfExists   string             `json:"if_exists,omitempty"`
+	State      string             `json:"state"`
+	Attempts   int                `json:"attempts"`
+	Error      string             `json:"error,omitempty"`
+	Tables     []TableCount       `json:"tables,omitempty"`
+	TotalRows  int64              `json:"total_rows"`
+	Outputs    []string           `json:"outputs,omitempty"`
+	Existing   []db.ExistingTable `json:"existing_tables,omitempty"`
+	Report     *db.DryRunReport   `json:"dry_run_report,omitempty"`
+	QueuedAt   time.Time          `json:"queued_at"`
+	StartedAt  *time.Time         `json:"started_at,omitempty"`
+	FinishedAt *time.Time         `json:"finished_at,omitempty"`
+	UpdatedAt  time.Time          `json:"updated_at"`
+}
+
+// Registry keeps the status of every job. With a Store attached every
+// change is written through, so the history survives restarts.
+type Registry struct {
+	mu       sync.RWMutex
+	jobs     map[string]*JobStatus
+	cancels  map[string]context.CancelFunc
+	trackers map[string]*tracker
+	store    *Store
+
+	// Subscribers have their own lock so events can be published while mu
+	// is held.
+	subsMu sync.Mutex
+	subs   map[string]map[chan Event]struct{}
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		jobs:     map[string]*JobStatus{},
+		cancels:  map[string]context.CancelFunc{},
+		trackers: map[string]*tracker{},
+		subs:     map[string]map[chan Event]struct{}{},
+	}
+}
+
+var registry = NewRegistry()
+
+func (r *Registry) add(job Job) {
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := &JobStatus{
+		ID:        job.ID,
+		FilePath:  job.FilePath,
+		Source:    job.Source,
+		Target:    job.Target,
+		DryRun:    job.DryRun,
+		IfExists:  job.IfExists,
+		State:     StateQueued,
+		QueuedAt:  now,
+		UpdatedAt: now,
+	}
+	r.jobs[job.ID] = s
+	if r.store != nil {
+		if err := r.store.insert(job, *s); err != nil {
+			log.Printf("Job store write error (%s): %v", job.ID, err)
+		}
+	}
+}
+
+// restore puts a job read back from the store into the registry.
+func (r *Registry) restore(s JobStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := copyStatus(&s)
+	r.jobs[s.ID] = &c
+}
+
+func (r *Registry) update(id string, fn func(*JobStatus)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.updateLocked(id, fn)
+}
+
+// updateLocked is update for callers already holding mu.
+func (r *Registry) updateLocked(id string, fn func(*JobStatus)) {
+	s, ok := r.jobs[id]
+	if !ok {
+		return
+	}
+	state := s.State
+	fn(s)
+	s.UpdatedAt = time.Now()
+	if r.store != nil {
+		if err := r.store.save(*s); err != nil {
+			log.Printf("Job store write error (%s): %v", id, err)
+		}
+	}
+	if s.State != state {
+		if t := r.trackers[id]; t != nil {
+			t.setStage(s.State)
+		}
+		r.publish(id, Event{Type: EventState, Data: copyStatus(s)})
+	}
+}
+
+func (r *Registry) setState(id, state string) {
+	r.update(id, func(s *JobStatus) {
+		s.State = state
+		if state == StateParsing && s.StartedAt == nil {
+			now := time.Now()
+			s.StartedAt = &now
+		}
+	})
+}
+
+func (r *Registry) finish(id string, err error) {
+	r.update(id, func(s *JobStatus) {
+		now := time.Now()
+		s.FinishedAt = &now
+		if err != nil {
+			s.State = StateFailed
+			s.Error = err.Error()
+			return
+		}
+		s.State = StateDone
+	})
+}
+
+// cancelled marks a job stopped on request; the error records where it was.
+func (r *Registry) cancelled(id string) {
+	r.update(id, markCancelled)
+}
+
+func markCancelled(s *JobStatus) {
+	now := time.Now()
+	s.FinishedAt = &now
+	s.Error = "cancelled while " + s.State
+	s.State = StateCancelled
+}
+
+// start registers the cancel function of a job a worker picked up. It
+// reports false for a job cancelled while it was queued, which must not run.
+// untrack drops the function once the job has reached a final state.
+func (r *Registry) start(id string, cancel context.CancelFunc) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.jobs[id]; ok && s.State == StateCancelled {
+		return false
+	}
+	r.cancels[id] = cancel
+	return true
+}
+
+func (r *Registry) untrack(id string) {
+	r.mu.Lock()
+	cancel := r.cancels[id]
+	delete(r.cancels, id)
+	r.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// Cancel stops a job. A queued job is marked cancelled right away and
+// skipped by the workers; a running job has its context cancelled and is
+// marked cancelled once the pipeline has unwound. Both happen under the
+// lock start takes, so a worker picking the job up at the same time either
+// sees it cancelled or has its context cancelled.
+func (r *Registry) Cancel(id string) (JobStatus, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.jobs[id]
+	if !ok {
+		return JobStatus{}, ErrJobNotFound
+	}
+	if isFinal(s.State) {
+		return copyStatus(s), ErrJobFinished
+	}
+	if cancel := r.cancels[id]; cancel != nil {
+		cancel()
+	} else {
+		r.updateLocked(id, markCancelled)
+	}
+	return copyStatus(s), nil
+}
+
+// newTracker starts following the progress of a job a worker picked up.
+func (r *Registry) newTracker(id string, bytesTotal int64) *tracker {
+	t := newTracker(id, bytesTotal, func(ev Event) { r.publish(id, ev) })
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.jobs[id]; ok {
+		t.p.State = s.State
+	}
+	r.trackers[id] = t
+	return t
+}
+
+func (r *Registry) setTables(id string, tables []TableCount) {
+	r.mu.RLock()
+	t := r.trackers[id]
+	r.mu.RUnlock()
+	if t != nil {
+		t.setTables(tables)
+	}
+}
+
+// Progress returns the latest progress of a job that ran since startup.
+func (r *Registry) Progress(id string) (JobProgress, bool) {
+	r.mu.RLock()
+	t := r.trackers[id]
+	r.mu.RUnlock()
+	if t == nil {
+		return JobProgress{}, false
+	}
+	return t.snapshot(), true
+}
+
+// Subscribe returns a channel receiving the events of a job until the
+// returned function is called. Progress events are dropped for a
+// subscriber that falls behind; stage changes are not.
+func (r *Registry) Subscribe(id string) (<-chan Event, func(), error) {
+	if _, ok := r.Get(id); !ok {
+		return nil, nil, ErrJobNotFound
+	}
+	ch := make(chan Event, 32)
+
+	r.subsMu.Lock()
+	if r.subs[id] == nil {
+		r.subs[id] = map[chan Event]struct{}{}
+	}
+	r.subs[id][ch] = struct{}{}
+	r.subsMu.Unlock()
+
+	return ch, func() {
+		r.subsMu.Lock()
+		defer r.subsMu.Unlock()
+		delete(r.subs[id], ch)
+		if len(r.subs[id]) == 0 {
+			delete(r.subs, id)
+		}
+	}, nil
+}
+
+func (r *Registry) publish(id string, ev Event) {
+	r.subsMu.Lock()
+	defer r.subsMu.Unlock()
+	for ch := range r.subs[id] {
+		select {
+		case ch <- ev:
+			continue
+		default:
+		}
+		if ev.Type == EventProgress {
+			continue
+		}
+		// Make room for a stage change by dropping the oldest event.
+		select {
+		case <-ch:
+		default:
+		}
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// Get returns a copy of the status of one job.
+func (r *Registry) Get(id string) (JobStatus, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.jobs[id]
+	if !ok {
+		return JobStatus{}, false
+	}
+	return copyStatus(s), true
+}
+
+// List returns every job, most recently queued first.
+func (r *Registry) List() []JobStatus {
+	r.mu.RLock()
+	list := make([]JobStatus, 0, len(r.jobs))
+	for _, s := range r.jobs {
+		list = append(list, copyStatus(s))
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].QueuedAt.After(list[j].QueuedAt) })
+	return list
+}
+
+// Finished reports whether the job reached a final state.
+func (s JobStatus) Finished() bool {
+	return isFinal(s.State)
+}
+
+func copyStatus(s *JobStatus) JobStatus {
+	c := *s
+	c.Tables = append([]TableCount(nil), s.Tables...)
+	c.Outputs = append([]string(nil), s.Outputs...)
+	c.Existing = append([]db.ExistingTable(nil), s.Existing...)
+	return c
+}
+
+// GetJob looks a job up in the worker pool's registry.
+func GetJob(id string) (JobStatus, bool) {
+	return registry.Get(id)
+}
+
+// CancelJob cancels a job of the worker pool's registry.
+func CancelJob(id string) (JobStatus, error) {
+	return registry.Cancel(id)
+}
+
+// JobProgressOf returns the progress of a job of the worker pool's registry.
+func JobProgressOf(id string) (JobProgress, bool) {
+	return registry.Progress(id)
+}
+
+// SubscribeJob subscribes to the events of a job of the worker pool's
+// registry.
+func SubscribeJob(id string) (<-chan Event, func(), error) {
+	return registry.Subscribe(id)
+}
+
+// ListJobs returns the jobs of the worker pool's registry.
+func ListJobs() []JobStatus {
+	return registry.List()
+}
@@ -0,0 +1,148 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestRegistryStates(t *testing.T) {
+	tests := []struct {
+		name      string
+		run       func(r *Registry, id string) error
+		wantState string
+		wantError string
+		wantErr   error
+	}{
+		{
+			name:      "queued",
+			run:       func(r *Registry, id string) error { return nil },
+			wantState: StateQueued,
+		},
+		{
+			name: "done",
+			run: func(r *Registry, id string) error {
+				r.setState(id, StateParsing)
+				r.setState(id, StateImporting)
+				r.finish(id, nil)
+				return nil
+			},
+			wantState: StateDone,
+		},
+		{
+			name: "failed",
+			run: func(r *Registry, id string) error {
+				r.setState(id, StateParsing)
+				r.finish(id, errors.New("boom"))
+				return nil
+			},
+			wantState: StateFailed,
+			wantError: "boom",
+		},
+		{
+			name: "cancelled while queued",
+			run: func(r *Registry, id string) error {
+				_, err := r.Cancel(id)
+				return err
+			},
+			wantState: StateCancelled,
+			wantError: "cancelled while queued",
+		},
+		{
+			name: "cancelled while running",
+			run: func(r *Registry, id string) error {
+				ctx, cancel := context.WithCancel(context.Background())
+				if !r.start(id, cancel) {
+					return errors.New("start refused")
+				}
+				r.setState(id, StateGenerating)
+				if _, err := r.Cancel(id); err != nil {
+					return err
+				}
+				if ctx.Err() == nil {
+					return errors.New("context not cancelled")
+				}
+				// The worker unwinds and reports it, see complete.
+				r.cancelled(id)
+				r.untrack(id)
+				return nil
+			},
+			wantState: StateCancelled,
+			wantError: "cancelled while generating",
+		},
+		{
+			name: "cancel after the end",
+			run: func(r *Registry, id string) error {
+				r.finish(id, nil)
+				_, err := r.Cancel(id)
+				return err
+			},
+			wantState: StateDone,
+			wantErr:   ErrJobFinished,
+		},
+		{
+			name: "cancel unknown job",
+			run: func(r *Registry, id string) error {
+				_, err := r.Cancel("missing")
+				return err
+			},
+			wantState: StateQueued,
+			wantErr:   ErrJobNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			r.add(Job{ID: "j1", FilePath: "dump.sql", Target: "sqlite"})
+			if err := tt.run(r, "j1"); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			s, ok := r.Get("j1")
+			if !ok {
+				t.Fatal("job not found")
+			}
+			if s.State != tt.wantState || s.Error != tt.wantError {
+				t.Errorf("got %s %q, want %s %q", s.State, s.Error, tt.wantState, tt.wantError)
+			}
+			if s.Finished() != isFinal(tt.wantState) || (s.FinishedAt != nil) != isFinal(tt.wantState) {
+				t.Errorf("finished: got %v at %v", s.Finished(), s.FinishedAt)
+			}
+		})
+	}
+}
+
+// TestRegistryCancelRace cancels jobs while workers pick them up: every
+// cancel must either keep the job from starting or reach its context.
+func TestRegistryCancelRace(t *testing.T) {
+	r := NewRegistry()
+	for i := 0; i < 500; i++ {
+		id := fmt.Sprintf("j%d", i)
+		r.add(Job{ID: id})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		var started bool
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			started = r.start(id, cancel)
+		}()
+		go func() {
+			defer wg.Done()
+			r.Cancel(id)
+		}()
+		wg.Wait()
+
+		s, _ := r.Get(id)
+		switch {
+		case started && ctx.Err() == nil:
+			t.Fatalf("%s: started and never cancelled (state %s)", id, s.State)
+		case !started && s.State != StateCancelled:
+			t.Fatalf("%s: refused but %s", id, s.State)
+		}
+		cancel()
+	}
+}
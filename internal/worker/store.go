@@ -0,0 +1,116 @@
+package worker
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	_ "modernc.org/sqlite"
+)
+
+// maxAttempts bounds how often a job interrupted by a crash or restart is
+// started again before it is marked failed.
+const maxAttempts = 3
+
+// Store persists jobs in a SQLite file so the queue survives restarts.
+type Store struct {
+	db *sql.DB
+}
+
+type storedJob struct {
+	Job    Job
+	Status JobStatus
+}
+
+func OpenStore(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("store folder error: %v", err)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("store open error: %v", err)
+	}
+	// One connection serializes writers; SQLite allows a single one anyway.
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`PRAGMA journal_mode=WAL;
+CREATE TABLE IF NOT EXISTS jobs (
+  id TEXT PRIMARY KEY,
+  job TEXT NOT NULL,
+  state TEXT NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  status TEXT NOT NULL,
+  queued_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_jobs_state ON jobs (state);`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("store schema error: %v", err)
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) insert(job Job, status JobStatus) error {
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	statusJSON, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT OR REPLACE INTO jobs (id, job, state, attempts, status, queued_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		job.ID, string(jobJSON), status.State, status.Attempts, string(statusJSON), status.QueuedAt.UTC().Format("2006-01-02T15:04:05.000000000Z"))
+	return err
+}
+
+func (s *Store) save(status JobStatus) error {
+	statusJSON, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`UPDATE jobs SET state = ?, attempts = ?, status = ? WHERE id = ?`,
+		status.State, status.Attempts, string(statusJSON), status.ID)
+	return err
+}
+
+// load returns every stored job in queue order.
+func (s *Store) load() ([]storedJob, error) {
+	rows, err := s.db.Query(`SELECT id, job, state, attempts, status FROM jobs ORDER BY queued_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []storedJob
+	for rows.Next() {
+		var id, jobJSON, state, statusJSON string
+		var attempts int
+		if err := rows.Scan(&id, &jobJSON, &state, &attempts, &statusJSON); err != nil {
+			return nil, err
+		}
+		var sj storedJob
+		if err := json.Unmarshal([]byte(jobJSON), &sj.Job); err != nil {
+			return nil, fmt.Errorf("stored job decode error: %v", err)
+		}
+		if err := json.Unmarshal([]byte(statusJSON), &sj.Status); err != nil {
+			return nil, fmt.Errorf("stored job decode error: %v", err)
+		}
+		// The columns are authoritative; operators may edit them by hand.
+		sj.Job.ID, sj.Status.ID = id, id
+		sj.Status.State, sj.Status.Attempts = state, attempts
+		jobs = append(jobs, sj)
+	}
+	return jobs, rows.Err()
+}
+
+func isFinal(state string) bool {
+	return state == StateDone || state == StateFailed || state == StateCancelled
+}
@@ -1,30 +1,96 @@
 package worker
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 
+	"bigdataimporter/internal/db"
 	"bigdataimporter/internal/executor"
 	"bigdataimporter/internal/generator"
 	"bigdataimporter/internal/parser"
 )
 
 type Job struct {
-	ID       string
-	FilePath string
-	Target   string
+	ID            string
+	FilePath      string
+	Source        string // input format; detected from the file when empty
+	Target        string
+	EmbedChildren bool
+	DryRun        bool   // validate against the target, then roll back
+	IfExists      string // policy for existing tables, see generator.IfExistsFail
 }
 
 var jobQueue chan Job
 
+// UseStore makes the queue durable: jobs are written to store as they are
+// queued and change state, and StartPool picks up unfinished ones again.
+func UseStore(store *Store) {
+	registry.store = store
+}
+
 func StartPool(workerCount int) {
 	jobQueue = make(chan Job, 100)
 	for i := 0; i < workerCount; i++ {
 		go workerLoop(i)
 	}
 	log.Printf("Worker pool started with %d workers", workerCount)
+
+	if registry.store != nil {
+		pending := recoverJobs(registry.store)
+		go func() {
+			for _, job := range pending {
+				jobQueue <- job
+			}
+		}()
+	}
+}
+
+// recoverJobs loads the stored history and returns the jobs a restart or
+// crash interrupted. Jobs that already used up their attempts are failed,
+// and so are jobs interrupted while importing that cannot start over, see
+// rerunnable.
+func recoverJobs(store *Store) []Job {
+	stored, err := store.load()
+	if err != nil {
+		log.Printf("Job store read error: %v", err)
+		return nil
+	}
+
+	var pending []Job
+	for _, sj := range stored {
+		registry.restore(sj.Status)
+		if isFinal(sj.Status.State) {
+			continue
+		}
+		if sj.Status.Attempts >= maxAttempts {
+			registry.finish(sj.Job.ID, fmt.Errorf("interrupted %d times, giving up", sj.Status.Attempts))
+			continue
+		}
+		if sj.Status.State == StateImporting && !rerunnable(sj.Job) {
+			registry.finish(sj.Job.ID, fmt.Errorf("interrupted during the import, the target may hold part of the rows; rerun with if_exists=%s or %s",
+				generator.IfExistsReplace, generator.IfExistsTruncate))
+			continue
+		}
+		registry.update(sj.Job.ID, func(s *JobStatus) {
+			s.State = StateQueued
+			s.Error = ""
+			s.FinishedAt = nil
+		})
+		pending = append(pending, sj.Job)
+		log.Printf("Job re-queued after restart: %s (attempt %d)", sj.Job.ID, sj.Status.Attempts+1)
+	}
+	return pending
+}
+
+// rerunnable reports whether a job interrupted while importing can start
+// from scratch. The tables and rows of the first attempt are in the target
+// by then: fail stops on them, skip leaves them half loaded and append loads
+// the rows again. A dry run rolls everything back.
+func rerunnable(job Job) bool {
+	return job.DryRun || job.IfExists == generator.IfExistsReplace || job.IfExists == generator.IfExistsTruncate
 }
 
 func Enqueue(job Job) {
@@ -32,120 +98,255 @@ func Enqueue(job Job) {
 		log.Println("Worker pool not started")
 		return
 	}
+	registry.add(job)
 	jobQueue <- job
 	log.Printf("Job queued: %s (%s -> %s)", job.ID, job.FilePath, job.Target)
 }
 
 func workerLoop(id int) {
 	for job := range jobQueue {
+		ctx, cancel := context.WithCancel(context.Background())
+		if !registry.start(job.ID, cancel) {
+			cancel()
+			log.Printf("[Worker %d] skipping cancelled job: %s", id, job.ID)
+			continue
+		}
 		log.Printf("[Worker %d] started job: %s", id, job.ID)
-		processJob(job)
+		registry.update(job.ID, func(s *JobStatus) { s.Attempts++ })
+		processJob(ctx, job)
 		log.Printf("[Worker %d] finished job: %s", id, job.ID)
 	}
 }
 
-func processJob(job Job) {
+func processJob(ctx context.Context, job Job) {
 	log.Printf("Processing job %s ...", job.ID)
 
+	t := registry.newTracker(job.ID, parser.InputSize(job.FilePath))
+	ctx = parser.WithProgress(ctx, t.callbacks())
+
+	if err := runJob(ctx, job); err != nil {
+		log.Printf("Job %s failed: %v", job.ID, err)
+		complete(ctx, job.ID, err)
+	}
+}
+
+// complete records the final state of a job and releases its context. A
+// job whose context was cancelled ends as cancelled whatever error the
+// interrupted step returned.
+func complete(ctx context.Context, id string, err error) {
+	if ctx.Err() != nil {
+		log.Printf("Job %s cancelled", id)
+		registry.cancelled(id)
+	} else {
+		registry.finish(id, err)
+	}
+	registry.untrack(id)
+}
+
+// runJob parses, generates and starts the import of one job. It returns
+// once the import has been handed to the executor, which then reports the
+// final state itself. The temporary files of ParseFile are removed on every
+// error return here, and otherwise once the executor has replayed the rows.
+func runJob(ctx context.Context, job Job) error {
 	if _, err := os.Stat(job.FilePath); os.IsNotExist(err) {
-		log.Printf("File not found: %s", job.FilePath)
-		return
+		return fmt.Errorf("file not found: %s", job.FilePath)
+	}
+	if !generator.ValidIfExists(job.IfExists) {
+		return fmt.Errorf("invalid if_exists: %s", job.IfExists)
 	}
 
-	parsedTables, err := parser.ParseSQLFile(job.FilePath)
+	registry.setState(job.ID, StateParsing)
+	parsedTables, rows, cleanup, err := parser.ParseFile(ctx, job.FilePath, job.Source)
 	if err != nil {
-		log.Printf("Parse error in %s: %v", job.FilePath, err)
-		return
+		return fmt.Errorf("parse error in %s: %v", job.FilePath, err)
 	}
 	if len(parsedTables) == 0 {
-		log.Printf("No tables found in %s", job.FilePath)
-		return
+		cleanup()
+		return fmt.Errorf("no tables found in %s", job.FilePath)
 	}
 	log.Printf("Parsed %d tables from %s", len(parsedTables), job.FilePath)
 
+	var counts []TableCount
+	for _, t := range parsedTables {
+		counts = append(counts, TableCount{Name: t.TableName, Rows: t.RowCount})
+	}
+	registry.update(job.ID, func(s *JobStatus) {
+		s.Tables = counts
+		s.TotalRows = 0
+		for _, tc := range counts {
+			s.TotalRows += tc.Rows
+		}
+	})
+	registry.setTables(job.ID, counts)
+
+	if err := ctx.Err(); err != nil {
+		cleanup()
+		return err
+	}
+
 	if err := os.MkdirAll("results", 0777); err != nil {
-		log.Printf("results folder error: %v", err)
-		return
+		cleanup()
+		return fmt.Errorf("results folder error: %v", err)
 	}
 
-	var genTables []generator.Table
-	for _, t := range parsedTables {
-		genTable := generator.Table{
-			TableName:  t.TableName,
-			Fields:     make([]generator.Field, len(t.Fields)),
-			Engine:     t.Engine,
-			Charset:    t.Charset,
-			PrimaryKey: t.PrimaryKeys,
-		}
-		for fi, f := range t.Fields {
-			genField := generator.Field{
-				Name:          f.Name,
-				Type:          f.Type,
-				Nullable:      f.Nullable,
-				PrimaryKey:    f.PrimaryKey,
-				AutoIncrement: f.AutoIncrement,
-				Default:       f.Default,
-				Index:         f.Index,
-			}
-			if f.ForeignKey != nil {
-				genField.ForeignKey = &generator.ForeignKey{
-					ReferencedTable: f.ForeignKey.ReferencedTable,
-					ReferencedField: f.ForeignKey.ReferencedField,
-				}
-			}
-			genTable.Fields[fi] = genField
-		}
-		genTables = append(genTables, genTable)
+	registry.setState(job.ID, StateGenerating)
+	genTables := GeneratorTables(parsedTables)
+
+	source := job.Source
+	if source == "" {
+		source, _ = parser.DetectFormat(job.FilePath)
+	}
+	types, err := executor.TypeMap("", source)
+	if err != nil {
+		cleanup()
+		return err
 	}
 
-	gen := selectGenerator(job.Target)
+	gen := SelectGenerator(job, types)
 	if gen == nil {
-		log.Printf("Unsupported target: %s", job.Target)
-		return
+		cleanup()
+		return fmt.Errorf("unsupported target: %s", job.Target)
 	}
 
 	output, err := gen.GenerateSchema(genTables)
 	if err != nil {
-		log.Printf("Schema generation error: %v", err)
-		return
+		cleanup()
+		return fmt.Errorf("schema generation error: %v", err)
 	}
 	if len(output) == 0 {
-		log.Printf("Empty schema generated for %s", job.Target)
-		return
+		cleanup()
+		return fmt.Errorf("empty schema generated for %s", job.Target)
 	}
 
-	mergedPath := filepath.Join("results", fmt.Sprintf("merged_%s.sql", job.Target))
+	mergedPath := filepath.Join("results", fmt.Sprintf("merged_%s%s", job.Target, SchemaExt(job.Target)))
 	if err := os.WriteFile(mergedPath, []byte(output), 0644); err != nil {
 		log.Printf("Failed to write merged file: %v", err)
 	} else {
 		log.Printf("Schema exported: %s", mergedPath)
+		addOutput(job.ID, mergedPath)
 	}
 
 	if err := gen.ImportData(genTables); err != nil {
 		log.Printf("Data import failed: %v", err)
 	}
 
+	if dw, ok := gen.(generator.DocumentWriter); ok {
+		if job.DryRun {
+			cleanup()
+			return fmt.Errorf("dry run needs a database target, not %s", job.Target)
+		}
+		docDir := filepath.Join("results", job.Target)
+		err := dw.WriteDocuments(ctx, docDir, genTables, rows)
+		cleanup()
+		if err != nil {
+			return fmt.Errorf("document export failed: %v", err)
+		}
+		log.Printf("Documents exported: %s", docDir)
+		addOutput(job.ID, docDir)
+		log.Printf("Job %s completed successfully.", job.ID)
+		complete(ctx, job.ID, nil)
+		return nil
+	}
+
 	log.Printf("Job %s completed successfully.", job.ID)
 
+	registry.setState(job.ID, StateImporting)
 	go func() {
 		log.Printf("Import başlatılıyor: %s (%s)", mergedPath, job.Target)
-		executor.Run(executor.Job{
+		execJob := executor.Job{
 			ID:       job.ID + "-import",
 			FilePath: mergedPath,
 			Target:   job.Target,
-		}, parsedTables)
+			IfExists: job.IfExists,
+			Types:    types,
+			Preflight: func(existing []db.ExistingTable) {
+				registry.update(job.ID, func(s *JobStatus) { s.Existing = existing })
+			},
+		}
+		if !job.DryRun {
+			err := executor.Run(ctx, execJob, parsedTables, rows)
+			cleanup()
+			complete(ctx, job.ID, err)
+			return
+		}
+		report, err := executor.DryRun(ctx, execJob, parsedTables, rows)
+		cleanup()
+		if report != nil {
+			registry.update(job.ID, func(s *JobStatus) { s.Report = report })
+		}
+		complete(ctx, job.ID, err)
 	}()
+	return nil
 }
 
-func selectGenerator(target string) generator.Generator {
-	switch target {
+func addOutput(id, path string) {
+	registry.update(id, func(s *JobStatus) {
+		s.Outputs = append(s.Outputs, path)
+	})
+}
+
+// GeneratorTables converts parsed tables into the generator's model.
+func GeneratorTables(parsedTables []parser.ParsedTable) []generator.Table {
+	var genTables []generator.Table
+	for _, t := range parsedTables {
+		genTable := generator.Table{
+			TableName:  t.TableName,
+			Fields:     make([]generator.Field, len(t.Fields)),
+			Engine:     t.Engine,
+			Charset:    t.Charset,
+			PrimaryKey: t.PrimaryKeys,
+			Indexes:    t.Indexes,
+
+			ForeignKeys: t.ForeignKeys,
+		}
+		for fi, f := range t.Fields {
+			genField := generator.Field{
+				Name:          f.Name,
+				Type:          f.Type,
+				Nullable:      f.Nullable,
+				PrimaryKey:    f.PrimaryKey,
+				Unique:        f.Unique,
+				AutoIncrement: f.AutoIncrement,
+				Default:       f.Default,
+				Index:         f.Index,
+				TypeInfo:      f.TypeInfo,
+			}
+			if f.ForeignKey != nil {
+				genField.ForeignKey = &generator.ForeignKey{
+					ReferencedTable: f.ForeignKey.ReferencedTable,
+					ReferencedField: f.ForeignKey.ReferencedField,
+				}
+			}
+			genTable.Fields[fi] = genField
+		}
+		genTables = append(genTables, genTable)
+	}
+	return genTables
+}
+
+// SelectGenerator returns the schema generator of a job's target, or nil.
+// types may be nil for the built-in type mapping.
+func SelectGenerator(job Job, types *generator.TypeMap) generator.Generator {
+	switch job.Target {
 	case "postgres", "postgresql":
-		return &generator.PostgreGenerator{}
+		return &generator.PostgreGenerator{IfExists: job.IfExists, Types: types}
 	case "mongo", "mongodb":
-		return &generator.MongoGenerator{}
+		return &generator.MongoGenerator{EmbedChildren: job.EmbedChildren, Types: types}
 	case "sqlite":
-		return &generator.SQLiteGenerator{}
+		return &generator.SQLiteGenerator{Types: types}
+	case "mysql", "mariadb":
+		return &generator.MySQLGenerator{Types: types}
 	default:
 		return nil
 	}
 }
+
+// SchemaExt is the extension of the schema file generated for a target.
+func SchemaExt(target string) string {
+	switch target {
+	case "mongo", "mongodb":
+		return ".js"
+	default:
+		return ".sql"
+	}
+}
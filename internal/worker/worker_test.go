@@ -0,0 +1,127 @@
+package worker
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// chdir moves the test into dir, where the worker writes results/, until
+// the test ends.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// waitFinished polls the registry until the job reaches a final state.
+func waitFinished(t *testing.T, id string) JobStatus {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		if s, ok := registry.Get(id); ok && s.Finished() {
+			return s
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return JobStatus{}
+}
+
+// TestImportGzippedSQLite runs a gzip-compressed SQLite database through
+// the whole pipeline into the SQLite target. The database is unpacked to a
+// temporary file that must outlive runJob, which returns before the
+// executor replays the rows, and be gone once the job is done.
+func TestImportGzippedSQLite(t *testing.T) {
+	root := t.TempDir()
+	tmp := filepath.Join(root, "tmp")
+	work := filepath.Join(root, "work")
+	for _, d := range []string{tmp, work, filepath.Join(root, "app")} {
+		if err := os.MkdirAll(d, 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("TMPDIR", tmp)
+
+	// The executor looks for /app/config.yaml, also one level up.
+	if err := os.WriteFile(filepath.Join(root, "app", "config.yaml"), []byte("database:\n  name: e2e\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	src := filepath.Join(root, "shop.sqlite")
+	conn, err := sql.Open("sqlite", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL)",
+		"INSERT INTO users VALUES (1, 'ada'), (2, 'linus'), (3, 'grace')",
+	} {
+		if _, err := conn.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conn.Close()
+
+	raw, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gzPath := filepath.Join(root, "shop.sqlite.gz")
+	f, err := os.Create(gzPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := gzip.NewWriter(f)
+	if _, err := zw.Write(raw); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(src)
+
+	chdir(t, work)
+	job := Job{ID: "gzipped-sqlite", FilePath: gzPath, Target: "sqlite"}
+	registry.add(job)
+	processJob(context.Background(), job)
+
+	s := waitFinished(t, job.ID)
+	if s.State != StateDone {
+		t.Fatalf("job ended %s: %s", s.State, s.Error)
+	}
+
+	out, err := sql.Open("sqlite", filepath.Join(work, "results", "e2e.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	var n int
+	if err := out.QueryRow("SELECT COUNT(*) FROM users").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("got %d users, want 3", n)
+	}
+
+	left, err := os.ReadDir(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range left {
+		t.Errorf("temporary file left behind: %s", e.Name())
+	}
+}